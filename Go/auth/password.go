@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt only looks at the first 72 bytes of a password
+const maxPasswordBytes = 72
+
+var ErrPasswordTooLong = errors.New("Password must not be longer than 72 bytes")
+
+// compared against when there is no stored hash, so a failed login takes
+// as long for an unknown username as for a wrong password
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+func HashPassword(password string) (string, error) {
+	if len(password) > maxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHashed reports whether a stored password is already a bcrypt hash.
+// Rows created before hashing was introduced still hold plaintext.
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") ||
+		strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword compares a password with the stored value. needsRehash is
+// true when the stored value is a legacy plaintext password (or a hash with
+// an outdated cost) that should be replaced after a successful login.
+func CheckPassword(stored, password string) (ok bool, needsRehash bool) {
+	if stored == "" {
+		SimulatePasswordCheck(password)
+		return false, false
+	}
+	if !IsHashed(stored) {
+		ok = stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err == nil && cost < bcrypt.DefaultCost
+}
+
+// SimulatePasswordCheck takes the time of a bcrypt comparison without
+// checking anything, for logins of accounts that do not exist or have no
+// password.
+func SimulatePasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("no account has this password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
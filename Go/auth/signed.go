@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("Invalid or expired link")
+
+// Sign returns a tamper-proof token carrying purpose, subject and an expiry,
+// for links sent by email. The token is not encrypted.
+func Sign(secret []byte, purpose string, subject string, expiresAt time.Time) string {
+	payload := purpose + "|" + subject + "|" + strconv.FormatInt(expiresAt.Unix(), 10)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signature(secret, encoded)
+}
+
+// Verify checks a token created by Sign for purpose and returns its subject.
+func Verify(secret []byte, purpose string, token string) (string, error) {
+	encoded, sig, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(sig), []byte(signature(secret, encoded))) {
+		return "", ErrInvalidSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 3 || parts[0] != purpose {
+		return "", ErrInvalidSignature
+	}
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", ErrInvalidSignature
+	}
+	return parts[1], nil
+}
+
+func signature(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CSRFToken returns the token a browser session authenticated by cookie must
+// send back on unsafe requests. It is bound to the access token, so it
+// changes when the tokens of the session are rotated.
+func CSRFToken(secret []byte, accessTokenHash string) string {
+	return signature(secret, "csrf|"+accessTokenHash)
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// lifetime of an access token issued by /api/login
+	AccessTokenTTL = 15 * time.Minute
+	// lifetime of a session, the refresh token is valid until then
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// GenerateToken returns a random opaque token for the client and the hash
+// that is stored server-side. The plain token is never persisted.
+func GenerateToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// prefix of personal access tokens, so they can be told apart from session
+// access tokens and found by secret scanners
+const PersonalTokenPrefix = "kbp_"
+
+// GeneratePersonalToken is GenerateToken for personal access tokens.
+func GeneratePersonalToken() (token string, hash string, err error) {
+	token, _, err = GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = PersonalTokenPrefix + token
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
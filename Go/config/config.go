@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	//login brute-force protection
+	LoginMaxFailures      int
+	LoginMaxFailuresPerIP int
+	LoginFailureWindow    time.Duration
+	LoginLockoutDuration  time.Duration
+	LoginBaseDelay        time.Duration
+	LoginMaxDelay         time.Duration
+
+	//environment the app runs in, development or production. it picks the
+	//defaults of the cookie and security header settings
+	Environment string
+
+	//public url of the frontend, used for links in emails and descriptions
+	AppBaseURL string
+
+	//cross-origin requests, disabled when CORSAllowOrigins is empty. the
+	//frontend at AppBaseURL is allowed by default in development. the
+	//wildcard * is only accepted without CORSAllowCredentials
+	CORSAllowOrigins     []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	//browser sessions, the access token is also set in the SessionCookie
+	//cookie when it is not empty. requests authenticated by the cookie must
+	//send the csrf_token of the login in the X-CSRF-Token header
+	SessionCookie  string
+	CookieSecure   bool
+	CookieSameSite string
+
+	//security headers, Strict-Transport-Security is only sent over https
+	//and not at all when HSTSMaxAge is 0
+	HSTSMaxAge            time.Duration
+	ContentSecurityPolicy string
+	FrameOptions          string
+
+	//username promoted to administrator at startup while there is no
+	//administrator yet, the app does not start when the user does not exist
+	BootstrapAdmin string
+
+	//key for signed links and csrf tokens, required. it must stay the same
+	//across restarts and instances
+	SecretKey []byte
+
+	//encryption of the IDCard column and the two-factor secrets. IDCardKeys
+	//is a list of id:base64key, new values are encrypted with
+	//IDCardActiveKey (the last key when empty). without keys both are
+	//derived from SecretKey
+	IDCardKeys      string
+	IDCardActiveKey string
+	IDCardIndexKey  string
+
+	//email verification
+	EmailVerificationTTL      time.Duration
+	EmailVerificationCooldown time.Duration
+
+	//password reset
+	PasswordResetTTL time.Duration
+
+	//password policy, the breached password check is off when
+	//PasswordBreachedFile is empty
+	PasswordMinLength     int
+	PasswordMinClasses    int
+	PasswordCheckUsername bool
+	PasswordHistory       int
+	PasswordBreachedFile  string
+
+	//board invitations
+	BoardInvitationTTL time.Duration
+
+	//two-factor authentication, the issuer is the name shown in
+	//authenticator apps
+	TOTPIssuer        string
+	LoginChallengeTTL time.Duration
+
+	//lifetime of the sessions admins get to act as another user
+	ImpersonationTTL time.Duration
+
+	//single sign-on, disabled when OIDCIssuer is empty
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+
+	//mail delivery, Mailer is one of smtp, file or memory
+	Mailer       string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+var App Config
+
+// minimum length of APP_SECRET
+const minSecretLength = 32
+
+// Load reads the configuration from the environment, falling back to the
+// defaults for every variable that is not set.
+func Load() {
+	environment := getString("APP_ENV", "development")
+	production := environment == "production"
+	appBaseURL := getString("APP_BASE_URL", "http://localhost:3000")
+
+	defaultOrigins := ""
+	defaultHSTSMaxAge := time.Duration(0)
+	if production {
+		defaultHSTSMaxAge = 365 * 24 * time.Hour
+	} else {
+		defaultOrigins = appBaseURL
+	}
+
+	App = Config{
+		LoginMaxFailures:      getInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxFailuresPerIP: getInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		LoginFailureWindow:    getDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutDuration:  getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginBaseDelay:        getDuration("LOGIN_BASE_DELAY", time.Second),
+		LoginMaxDelay:         getDuration("LOGIN_MAX_DELAY", 30*time.Second),
+
+		Environment: environment,
+		AppBaseURL:  appBaseURL,
+
+		CORSAllowOrigins:     getList("CORS_ALLOW_ORIGINS", defaultOrigins),
+		CORSAllowCredentials: getBool("CORS_ALLOW_CREDENTIALS", true),
+		CORSMaxAge:           getDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		SessionCookie:  getString("SESSION_COOKIE", ""),
+		CookieSecure:   getBool("COOKIE_SECURE", production),
+		CookieSameSite: getString("COOKIE_SAMESITE", "Lax"),
+
+		HSTSMaxAge:            getDuration("HSTS_MAX_AGE", defaultHSTSMaxAge),
+		ContentSecurityPolicy: getString("CONTENT_SECURITY_POLICY", "default-src 'none'; frame-ancestors 'none'"),
+		FrameOptions:          getString("FRAME_OPTIONS", "DENY"),
+
+		BootstrapAdmin: getString("ADMIN_BOOTSTRAP_USERNAME", ""),
+		SecretKey:      []byte(getString("APP_SECRET", "")),
+
+		IDCardKeys:      getString("ID_CARD_KEYS", ""),
+		IDCardActiveKey: getString("ID_CARD_ACTIVE_KEY", ""),
+		IDCardIndexKey:  getString("ID_CARD_INDEX_KEY", ""),
+
+		EmailVerificationTTL:      getDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		EmailVerificationCooldown: getDuration("EMAIL_VERIFICATION_COOLDOWN", 5*time.Minute),
+
+		PasswordResetTTL: getDuration("PASSWORD_RESET_TTL", time.Hour),
+
+		PasswordMinLength:     getInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinClasses:    getInt("PASSWORD_MIN_CLASSES", 2),
+		PasswordCheckUsername: getBool("PASSWORD_CHECK_USERNAME", true),
+		PasswordHistory:       getInt("PASSWORD_HISTORY", 5),
+		PasswordBreachedFile:  getString("PASSWORD_BREACHED_FILE", ""),
+
+		BoardInvitationTTL: getDuration("BOARD_INVITATION_TTL", 7*24*time.Hour),
+
+		TOTPIssuer:        getString("TOTP_ISSUER", "KanBan Board"),
+		LoginChallengeTTL: getDuration("LOGIN_CHALLENGE_TTL", 5*time.Minute),
+
+		ImpersonationTTL: getDuration("IMPERSONATION_TTL", 15*time.Minute),
+
+		OIDCIssuer:       getString("OIDC_ISSUER", ""),
+		OIDCClientID:     getString("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getString("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getString("OIDC_REDIRECT_URL", "http://localhost:8000/api/oidc/callback"),
+		OIDCScopes:       strings.Fields(getString("OIDC_SCOPES", "openid email profile")),
+
+		Mailer:       getString("MAILER", "file"),
+		MailFrom:     getString("MAIL_FROM", "kanban@localhost"),
+		MailDir:      getString("MAIL_DIR", "tmp/mail"),
+		SMTPHost:     getString("SMTP_HOST", "localhost"),
+		SMTPPort:     getInt("SMTP_PORT", 587),
+		SMTPUsername: getString("SMTP_USERNAME", ""),
+		SMTPPassword: getString("SMTP_PASSWORD", ""),
+	}
+}
+
+// Validate reports the settings the app can not start with. There are no
+// random fallbacks for keys, data encrypted or signed with them would be
+// lost on the next restart.
+func Validate() error {
+	if len(App.SecretKey) < minSecretLength {
+		return fmt.Errorf("APP_SECRET must be set to a stable value of at least %d characters", minSecretLength)
+	}
+	return validateCORSOrigins(App.CORSAllowOrigins, App.CORSAllowCredentials)
+}
+
+// browsers do not send credentials to a wildcard origin, so it can not be
+// combined with CORS_ALLOW_CREDENTIALS. other origins are scheme://host, a
+// subdomain wildcard like https://*.example.com is allowed.
+func validateCORSOrigins(origins []string, credentials bool) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			if credentials {
+				return errors.New("CORS_ALLOW_ORIGINS=* can not be used with CORS_ALLOW_CREDENTIALS=true, list the allowed origins or disable credentials")
+			}
+			if len(origins) > 1 {
+				return errors.New("CORS_ALLOW_ORIGINS=* can not be combined with other origins")
+			}
+			continue
+		}
+
+		parsed, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || strings.Contains(parsed.Host, "*") ||
+			(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("CORS_ALLOW_ORIGINS: %q is not an origin like https://example.com", origin)
+		}
+	}
+	return nil
+}
+
+func getString(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// comma separated values, empty ones are dropped
+func getList(key string, fallback string) []string {
+	values := []string{}
+	for _, value := range strings.Split(getString(key, fallback), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getString(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getString(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(getString(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequiresSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  *string
+		wantErr bool
+	}{
+		{"not set", nil, true},
+		{"empty", ptr(""), true},
+		{"too short", ptr("secret"), true},
+		{"long enough", ptr(strings.Repeat("s", minSecretLength)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_SECRET", "")
+			if tt.secret == nil {
+				unsetenv(t, "APP_SECRET")
+			} else {
+				t.Setenv("APP_SECRET", *tt.secret)
+			}
+
+			Load()
+			if err := Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// the secret is never generated, two loads give the same key
+func TestLoadKeepsSecret(t *testing.T) {
+	t.Setenv("APP_SECRET", "")
+	unsetenv(t, "APP_SECRET")
+
+	Load()
+	if len(App.SecretKey) != 0 {
+		t.Errorf("SecretKey = %q without APP_SECRET", App.SecretKey)
+	}
+}
+
+func ptr(value string) *string {
+	return &value
+}
+
+// call t.Setenv first, it restores the variable after the test
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	if err := os.Unsetenv(key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		wantErr     bool
+	}{
+		{"none", nil, true, false},
+		{"origins with credentials", []string{"https://app.example.com", "http://localhost:3000"}, true, false},
+		{"subdomain wildcard", []string{"https://*.example.com"}, true, false},
+		{"trailing slash", []string{"https://app.example.com/"}, true, false},
+		{"wildcard without credentials", []string{"*"}, false, false},
+		{"wildcard with credentials", []string{"*"}, true, true},
+		{"wildcard with other origins", []string{"*", "https://app.example.com"}, false, true},
+		{"no scheme", []string{"app.example.com"}, false, true},
+		{"path", []string{"https://app.example.com/login"}, false, true},
+		{"wildcard host", []string{"https://*"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCORSOrigins(tt.origins, tt.credentials); (err != nil) != tt.wantErr {
+				t.Errorf("validateCORSOrigins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
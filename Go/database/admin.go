@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gofiber/models"
+
+	"gorm.io/gorm"
+)
+
+func addAdministration() error {
+	if err := createTables(&models.AuditLog{}); err != nil {
+		return err
+	}
+	_, err := addColumns(&models.User{}, "DeactivatedAt")
+	return err
+}
+
+// BootstrapAdmin promotes username to administrator when no user is an
+// administrator yet. It does nothing once there is an administrator.
+func BootstrapAdmin(username string) error {
+	if username == "" {
+		return nil
+	}
+
+	var count int64
+	if err := DB.Model(&models.User{}).Where("is_admin = ?", true).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return GrantAdmin(username)
+}
+
+// GrantAdmin promotes username to administrator. The change is audited as
+// an action of the system.
+func GrantAdmin(username string) error {
+	var user models.User
+	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %q does not exist", username)
+		}
+		return err
+	}
+	if user.IsAdmin {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("is_admin", true).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{
+			Action:     models.AuditGrantAdmin,
+			TargetType: models.AuditTargetUser,
+			TargetID:   user.ID,
+			Details:    `{"source":"bootstrap"}`,
+		}).Error
+	})
+}
@@ -0,0 +1,7 @@
+package database
+
+import "gofiber/models"
+
+func addBoardInvitations() error {
+	return createTables(&models.BoardInvitation{})
+}
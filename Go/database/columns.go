@@ -0,0 +1,74 @@
+package database
+
+import (
+	"gofiber/models"
+
+	"gorm.io/gorm"
+)
+
+const boardBatchSize = 100
+
+// boards used to have the same four columns, checked by name. add the
+// color, position and done flag, widen the name and give every board the
+// default columns it is missing. the columns of a migrated board all have a
+// color, so boards with a column without one are migrated on every start
+// until a migration that stopped halfway is complete.
+func addColumnSettings() error {
+	if err := widenColumn(&models.ColumnBoard{}, "ColumnName", "column_name", 50); err != nil {
+		return err
+	}
+	if _, err := addColumns(&models.ColumnBoard{}, "Color", "Position", "IsDone"); err != nil {
+		return err
+	}
+	if !DB.Migrator().HasTable(&models.ColumnBoard{}) {
+		return nil
+	}
+
+	pending := DB.Model(&models.ColumnBoard{}).
+		Select("board_id").
+		Where("color IS NULL OR color = ''")
+	boards := []models.Board{}
+	result := DB.Where("id IN (?)", pending).FindInBatches(&boards, boardBatchSize, func(tx *gorm.DB, batch int) error {
+		return DB.Transaction(func(tx *gorm.DB) error {
+			for _, board := range boards {
+				if err := migrateBoardColumns(tx, board.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	return result.Error
+}
+
+// existing columns keep their id and tasks, and get the settings of the
+// default column with the same name. other columns get the default color.
+func migrateBoardColumns(tx *gorm.DB, boardID uint) error {
+	columns := []models.ColumnBoard{}
+	tx.Where("board_id = ?", boardID).Find(&columns)
+	existing := map[string]models.ColumnBoard{}
+	for _, column := range columns {
+		existing[column.ColumnName] = column
+	}
+
+	for _, column := range models.DefaultColumns(boardID) {
+		current, ok := existing[column.ColumnName]
+		if !ok {
+			if err := tx.Create(&column).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		err := tx.Model(&current).Updates(map[string]interface{}{
+			"color":    column.Color,
+			"position": column.Position,
+			"is_done":  column.IsDone,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.ColumnBoard{}).
+		Where("board_id = ? AND (color IS NULL OR color = '')", boardID).
+		Update("color", models.DefaultColumnColor).Error
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"gofiber/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// a column as it was stored before columns had settings
+type legacyColumn struct {
+	gorm.Model
+	ColumnName string
+	BoardID    uint
+}
+
+func (legacyColumn) TableName() string {
+	return "column_boards"
+}
+
+func TestAddColumnSettingsCompletesStoppedMigration(t *testing.T) {
+	setupTestDB(t, &models.Board{}, &models.ColumnBoard{})
+
+	//the settings columns were added, but the start stopped before this
+	//board was migrated
+	pending := models.Board{BoardName: "Pending"}
+	DB.Create(&pending)
+	DB.Create(&legacyColumn{ColumnName: "Doing", BoardID: pending.ID})
+	DB.Create(&legacyColumn{ColumnName: "Review", BoardID: pending.ID})
+
+	//a migrated board whose owner removed a column and changed a color
+	migrated := models.Board{BoardName: "Migrated"}
+	DB.Create(&migrated)
+	DB.Create(&models.ColumnBoard{BoardID: migrated.ID, ColumnName: "To Do", Color: "#000000"})
+
+	for range 2 {
+		if err := addColumnSettings(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	columns := []models.ColumnBoard{}
+	DB.Where("board_id = ?", pending.ID).Order("id").Find(&columns)
+	if len(columns) != 5 {
+		t.Fatalf("pending board has %d columns, want 5", len(columns))
+	}
+	for _, column := range columns {
+		if column.Color == "" {
+			t.Errorf("column %q has no color", column.ColumnName)
+		}
+		if column.ColumnName == "Doing" && column.Position != 1 {
+			t.Errorf("Doing position = %d, want 1", column.Position)
+		}
+	}
+
+	columns = []models.ColumnBoard{}
+	DB.Where("board_id = ?", migrated.ID).Find(&columns)
+	if len(columns) != 1 || columns[0].Color != "#000000" {
+		t.Errorf("migrated board columns = %+v, want it unchanged", columns)
+	}
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"gofiber/models"
 	"time"
 
 	"gorm.io/driver/mysql"
@@ -34,5 +35,96 @@ func ConnectDB() {
 		panic(err)
 	}
 
-	//DB.AutoMigrate(&models.User{}, &models.Login{}, &models.Board{}, &models.BoardMember{}, &models.ColumnBoard{}, &models.Task{}, &models.TaskAssignee{})
+	if err := widenPasswordColumn(); err != nil {
+		panic(err)
+	}
+	if err := addSessionTokens(); err != nil {
+		panic(err)
+	}
+	if err := addRefreshTokens(); err != nil {
+		panic(err)
+	}
+	if err := addPersonalAccessTokens(); err != nil {
+		panic(err)
+	}
+	if err := addLoginAttempts(); err != nil {
+		panic(err)
+	}
+	if err := addPasswordResets(); err != nil {
+		panic(err)
+	}
+	if err := addEmailVerification(); err != nil {
+		panic(err)
+	}
+	if err := addTwoFactor(); err != nil {
+		panic(err)
+	}
+	if err := addExternalIdentities(); err != nil {
+		panic(err)
+	}
+	if err := encryptIDCards(); err != nil {
+		panic(err)
+	}
+	if err := addAdministration(); err != nil {
+		panic(err)
+	}
+	if err := addBoardInvitations(); err != nil {
+		panic(err)
+	}
+	if err := addImpersonation(); err != nil {
+		panic(err)
+	}
+	if err := addPasswordHistory(); err != nil {
+		panic(err)
+	}
+	if err := addColumnSettings(); err != nil {
+		panic(err)
+	}
+	if err := addTaskRanks(); err != nil {
+		panic(err)
+	}
+	if err := addTaskDescriptions(); err != nil {
+		panic(err)
+	}
+
+	//DB.AutoMigrate(&models.User{}, &models.Login{}, &models.Board{}, &models.BoardMember{}, &models.ColumnBoard{}, &models.Task{}, &models.TaskAssignee{}, &models.PersonalAccessToken{}, &models.PersonalAccessTokenBoard{}, &models.LoginAttempt{}, &models.LoginLockout{}, &models.PasswordReset{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginChallenge{}, &models.ExternalIdentity{}, &models.OIDCAuthRequest{}, &models.AuditLog{}, &models.BoardInvitation{}, &models.PasswordHistory{})
+}
+
+// the password column used to be size:10 plaintext, widen it so it can hold
+// bcrypt hashes. existing plaintext rows are rehashed on the next login.
+func widenPasswordColumn() error {
+	if !DB.Migrator().HasTable(&models.User{}) {
+		return nil
+	}
+
+	columnTypes, err := DB.Migrator().ColumnTypes(&models.User{})
+	if err != nil {
+		return err
+	}
+	for _, column := range columnTypes {
+		if column.Name() != "password" {
+			continue
+		}
+		if length, ok := column.Length(); ok && length >= 255 {
+			return nil
+		}
+	}
+	return DB.Migrator().AlterColumn(&models.User{}, "Password")
+}
+
+// accounts created before email verification existed are treated as
+// verified, only new accounts have to confirm their address
+func addEmailVerification() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.User{}) || migrator.HasColumn(&models.User{}, "EmailVerifiedAt") {
+		return nil
+	}
+
+	if err := migrator.AddColumn(&models.User{}, "EmailVerifiedAt"); err != nil {
+		return err
+	}
+	if err := migrator.AddColumn(&models.User{}, "VerificationSentAt"); err != nil {
+		return err
+	}
+	return DB.Exec("UPDATE users SET email_verified_at = created_at").Error
 }
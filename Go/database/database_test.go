@@ -0,0 +1,61 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"gofiber/keyring"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// replaces DB with an empty in-memory database with the tables of models
+func setupTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every connection would open its own in-memory database
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+		sqlDB.Close()
+	})
+}
+
+// sets keyring.Default to a ring of the given keys, the last one active.
+// the key of an id is the same in every ring.
+func setupTestKeyRing(t *testing.T, ids ...string) {
+	t.Helper()
+
+	keys := map[string][]byte{}
+	for _, id := range ids {
+		key := sha256.Sum256([]byte(id))
+		keys[id] = key[:]
+	}
+	ring, err := keyring.New(keys, ids[len(ids)-1], bytes.Repeat([]byte{'i'}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := keyring.Default
+	keyring.Default = ring
+	t.Cleanup(func() { keyring.Default = previous })
+}
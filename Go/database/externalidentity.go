@@ -0,0 +1,12 @@
+package database
+
+import "gofiber/models"
+
+// the email column used to be size:20, too short for the addresses returned
+// by identity providers
+func addExternalIdentities() error {
+	if err := createTables(&models.ExternalIdentity{}, &models.OIDCAuthRequest{}); err != nil {
+		return err
+	}
+	return widenColumn(&models.User{}, "Email", "email", 255)
+}
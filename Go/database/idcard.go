@@ -0,0 +1,74 @@
+package database
+
+import (
+	"fmt"
+	"gofiber/keyring"
+	"gofiber/models"
+
+	"gorm.io/gorm"
+)
+
+const idCardBatchSize = 100
+
+// the id_card column used to be size:13 plaintext with a unique index.
+// widen it for ciphertext, move the unique check to the blind index and
+// encrypt the rows that are still in clear text.
+func encryptIDCards() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.User{}) {
+		return nil
+	}
+
+	if !migrator.HasColumn(&models.User{}, "IDCardIndex") {
+		if err := migrator.AlterColumn(&models.User{}, "IDCard"); err != nil {
+			return err
+		}
+		if migrator.HasIndex(&models.User{}, "id_card") {
+			if err := migrator.DropIndex(&models.User{}, "id_card"); err != nil {
+				return err
+			}
+		}
+		if err := migrator.AddColumn(&models.User{}, "IDCardIndex"); err != nil {
+			return err
+		}
+		if err := migrator.CreateIndex(&models.User{}, "IDCardIndex"); err != nil {
+			return err
+		}
+	}
+
+	_, err := rewriteIDCards(DB.Where("id_card NOT LIKE ? OR id_card_index IS NULL", "enc:%"))
+	return err
+}
+
+// ReencryptIDCards encrypts every IDCard that is not encrypted with the
+// active key yet, so retired keys can be removed from the ring. The blind
+// index is computed again as well. It returns the number of updated users.
+func ReencryptIDCards() (int, error) {
+	activePrefix := fmt.Sprintf("enc:v1:%s:%%", keyring.Default.ActiveKeyID())
+	return rewriteIDCards(DB.Where("id_card NOT LIKE ?", activePrefix))
+}
+
+// saves the IDCard of the users matching query again, which encrypts it
+// with the active key
+func rewriteIDCards(query *gorm.DB) (int, error) {
+	updated := 0
+	users := []models.User{}
+	result := query.Unscoped().
+		Where("id_card IS NOT NULL").
+		FindInBatches(&users, idCardBatchSize, func(tx *gorm.DB, batch int) error {
+			return DB.Transaction(func(tx *gorm.DB) error {
+				for _, user := range users {
+					user.SetIDCard(user.IDCard)
+					err := tx.Model(&user).Unscoped().
+						Select("IDCard", "IDCardIndex").
+						Updates(&user).Error
+					if err != nil {
+						return err
+					}
+					updated++
+				}
+				return nil
+			})
+		})
+	return updated, result.Error
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"gofiber/models"
+	"strings"
+	"testing"
+)
+
+// stored values of a column, bypassing the serializer
+func storedValues(t *testing.T, table string, column string) []string {
+	t.Helper()
+	values := []string{}
+	if err := DB.Table(table).Where(column+" IS NOT NULL").Pluck(column, &values).Error; err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func createTestUsers(t *testing.T) []models.User {
+	t.Helper()
+	users := []models.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+		{Username: "carol", Email: "carol@example.com"},
+		{Username: "sso", Email: "sso@example.com"},
+	}
+	users[0].SetIDCard("1111111111111")
+	users[1].SetIDCard("2222222222222")
+	users[2].SetIDCard("3333333333333")
+	if err := DB.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	//deactivated and deleted accounts are re-encrypted too
+	DB.Delete(&users[2])
+	return users
+}
+
+func TestReencryptIDCards(t *testing.T) {
+	setupTestDB(t, &models.User{})
+	setupTestKeyRing(t, "old")
+	users := createTestUsers(t)
+
+	setupTestKeyRing(t, "old", "new")
+	updated, err := ReencryptIDCards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 3 {
+		t.Errorf("ReencryptIDCards() = %d, want 3", updated)
+	}
+	for _, value := range storedValues(t, "users", "id_card") {
+		if !strings.HasPrefix(value, "enc:v1:new:") {
+			t.Errorf("id_card %q is not encrypted with the new key", value)
+		}
+	}
+
+	//nothing is left for the old key
+	if updated, err := ReencryptIDCards(); err != nil || updated != 0 {
+		t.Errorf("second ReencryptIDCards() = %d, %v, want 0", updated, err)
+	}
+
+	//the old key can be removed, the numbers and the blind index stay
+	setupTestKeyRing(t, "new")
+	for _, want := range users {
+		var user models.User
+		if err := DB.Unscoped().First(&user, want.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if user.IDCard != want.IDCard {
+			t.Errorf("IDCard of %s = %q, want %q", want.Username, user.IDCard, want.IDCard)
+		}
+		if (user.IDCardIndex == nil) != (want.IDCardIndex == nil) ||
+			(user.IDCardIndex != nil && *user.IDCardIndex != *want.IDCardIndex) {
+			t.Errorf("blind index of %s changed", want.Username)
+		}
+	}
+}
+
+func TestEncryptIDCardsEncryptsPlainText(t *testing.T) {
+	setupTestDB(t, &models.User{})
+	setupTestKeyRing(t, "k1")
+	users := createTestUsers(t)
+
+	//rows written before the column was encrypted
+	DB.Exec("UPDATE users SET id_card = ?, id_card_index = NULL WHERE id = ?", "1111111111111", users[0].ID)
+
+	if err := encryptIDCards(); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range storedValues(t, "users", "id_card") {
+		if !strings.HasPrefix(value, "enc:v1:k1:") {
+			t.Errorf("id_card %q is not encrypted", value)
+		}
+	}
+
+	var user models.User
+	DB.First(&user, users[0].ID)
+	if user.IDCard != "1111111111111" || user.IDCardIndex == nil || *user.IDCardIndex != *users[0].IDCardIndex {
+		t.Errorf("migrated user = %q, index %v", user.IDCard, user.IDCardIndex)
+	}
+}
+
+func TestReencryptTwoFactorSecrets(t *testing.T) {
+	setupTestDB(t, &models.User{}, &models.TwoFactor{})
+	setupTestKeyRing(t, "old")
+	users := createTestUsers(t)
+	for _, user := range users[:2] {
+		DB.Create(&models.TwoFactor{UserID: user.ID, Secret: "SECRET" + user.Username})
+	}
+
+	setupTestKeyRing(t, "old", "new")
+	if updated, err := ReencryptTwoFactorSecrets(); err != nil || updated != 2 {
+		t.Errorf("ReencryptTwoFactorSecrets() = %d, %v, want 2", updated, err)
+	}
+	for _, value := range storedValues(t, "two_factors", "secret") {
+		if !strings.HasPrefix(value, "enc:v1:new:") {
+			t.Errorf("secret %q is not encrypted with the new key", value)
+		}
+	}
+
+	setupTestKeyRing(t, "new")
+	var twoFactor models.TwoFactor
+	DB.Where("user_id = ?", users[0].ID).First(&twoFactor)
+	if twoFactor.Secret != "SECRETalice" {
+		t.Errorf("Secret = %q, want SECRETalice", twoFactor.Secret)
+	}
+}
@@ -0,0 +1,33 @@
+package database
+
+import "gofiber/models"
+
+// logins used to be plain records without a token. they can not be used as
+// sessions, so the rows that exist before the token columns are expired.
+func addSessionTokens() error {
+	added, err := addColumns(&models.Login{}, "TokenHash", "ExpiresAt")
+	if err != nil || !added {
+		return err
+	}
+	return DB.Exec("UPDATE logins SET expires_at = created_at WHERE token_hash IS NULL").Error
+}
+
+// sessions from before refresh tokens can not be refreshed, they end when
+// their access token expires
+func addRefreshTokens() error {
+	added, err := addColumns(&models.Login{}, "RefreshTokenHash", "RefreshExpiresAt", "ClientIP", "UserAgent", "RevokedAt")
+	if err != nil || !added {
+		return err
+	}
+	return DB.Exec("UPDATE logins SET refresh_expires_at = expires_at WHERE refresh_token_hash IS NULL").Error
+}
+
+// impersonation sessions record the admin behind them, and the audit log the
+// user a request ran as
+func addImpersonation() error {
+	if _, err := addColumns(&models.Login{}, "ImpersonatorUserID"); err != nil {
+		return err
+	}
+	_, err := addColumns(&models.AuditLog{}, "EffectiveUserID")
+	return err
+}
@@ -0,0 +1,13 @@
+package database
+
+import "gofiber/models"
+
+// lockouts are lifted by administrators, so the admin flag comes with the
+// login history tables
+func addLoginAttempts() error {
+	if err := createTables(&models.LoginAttempt{}, &models.LoginLockout{}); err != nil {
+		return err
+	}
+	_, err := addColumns(&models.User{}, "IsAdmin")
+	return err
+}
@@ -0,0 +1,7 @@
+package database
+
+import "gofiber/models"
+
+func addPasswordHistory() error {
+	return createTables(&models.PasswordHistory{})
+}
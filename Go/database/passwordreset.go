@@ -0,0 +1,7 @@
+package database
+
+import "gofiber/models"
+
+func addPasswordResets() error {
+	return createTables(&models.PasswordReset{})
+}
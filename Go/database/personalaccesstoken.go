@@ -0,0 +1,7 @@
+package database
+
+import "gofiber/models"
+
+func addPersonalAccessTokens() error {
+	return createTables(&models.PersonalAccessToken{}, &models.PersonalAccessTokenBoard{})
+}
@@ -0,0 +1,78 @@
+package database
+
+// AutoMigrate is not run on startup, these helpers bring an existing schema
+// up to date one change at a time.
+
+// creates the tables of the models that do not exist yet
+func createTables(models ...interface{}) error {
+	migrator := DB.Migrator()
+	for _, model := range models {
+		if migrator.HasTable(model) {
+			continue
+		}
+		if err := migrator.CreateTable(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adds the fields of model that are missing from its table together with
+// the indexes declared on them. reports whether any column was added, so the
+// caller can backfill existing rows once.
+func addColumns(model interface{}, fields ...string) (bool, error) {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(model) {
+		return false, nil
+	}
+
+	added := false
+	for _, field := range fields {
+		if migrator.HasColumn(model, field) {
+			continue
+		}
+		if err := migrator.AddColumn(model, field); err != nil {
+			return added, err
+		}
+		added = true
+	}
+
+	stmt := DB.Model(model).Statement
+	if err := stmt.Parse(model); err != nil {
+		return added, err
+	}
+	for _, field := range fields {
+		index := stmt.Schema.LookIndex(field)
+		if index == nil || migrator.HasIndex(model, index.Name) {
+			continue
+		}
+		if err := migrator.CreateIndex(model, index.Name); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// alters the column of field to its declared type while the existing column
+// holds less than length characters
+func widenColumn(model interface{}, field string, column string, length int64) error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(model) {
+		return nil
+	}
+
+	columnTypes, err := migrator.ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+	for _, columnType := range columnTypes {
+		if columnType.Name() != column {
+			continue
+		}
+		if current, ok := columnType.Length(); ok && current >= length {
+			return nil
+		}
+		return migrator.AlterColumn(model, field)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package database
+
+import "gofiber/models"
+
+// size of a mediumtext column in bytes
+const mediumTextLength = 16777215
+
+// task titles used to be one of New, In Progress or Completed in a size:20
+// column. widen the column for free text and add the description, existing
+// titles are kept as they are.
+func addTaskDescriptions() error {
+	if err := widenColumn(&models.Task{}, "Title", "title", 255); err != nil {
+		return err
+	}
+	if _, err := addColumns(&models.Task{}, "Description"); err != nil {
+		return err
+	}
+
+	//descriptions used to be text, which holds 65535 bytes and not 20000
+	//characters of up to four bytes
+	return widenColumn(&models.Task{}, "Description", "description", mediumTextLength)
+}
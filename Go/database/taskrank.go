@@ -0,0 +1,48 @@
+package database
+
+import (
+	"gofiber/models"
+	"gofiber/rank"
+
+	"gorm.io/gorm"
+)
+
+const columnBatchSize = 100
+
+// tasks had no order. rank the tasks of every column in the order they were
+// created. columns with unranked tasks are ranked again on every start, so a
+// backfill that stopped halfway is completed. ranked tasks keep their order
+// and unranked ones follow them.
+func addTaskRanks() error {
+	if _, err := addColumns(&models.Task{}, "Rank"); err != nil {
+		return err
+	}
+	if !DB.Migrator().HasTable(&models.Task{}) {
+		return nil
+	}
+
+	pending := DB.Unscoped().Model(&models.Task{}).
+		Select("column_board_id").
+		Where("rank_key IS NULL OR rank_key = ''")
+	columns := []models.ColumnBoard{}
+	result := DB.Unscoped().Where("id IN (?)", pending).FindInBatches(&columns, columnBatchSize, func(tx *gorm.DB, batch int) error {
+		return DB.Transaction(func(tx *gorm.DB) error {
+			for _, column := range columns {
+				var ids []uint
+				tx.Unscoped().Model(&models.Task{}).
+					Where("column_board_id = ?", column.ID).
+					Order("CASE WHEN rank_key IS NULL OR rank_key = '' THEN 1 ELSE 0 END, COALESCE(rank_key, ''), id").
+					Pluck("id", &ids)
+				for i, key := range rank.Spread(len(ids)) {
+					if err := tx.Unscoped().Model(&models.Task{}).
+						Where("id = ?", ids[i]).
+						UpdateColumn("rank_key", key).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+	return result.Error
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"gofiber/models"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestAddTaskRanksCompletesStoppedBackfill(t *testing.T) {
+	setupTestDB(t, &models.Board{}, &models.ColumnBoard{}, &models.Task{})
+
+	board := models.Board{BoardName: "Board"}
+	DB.Create(&board)
+	pending := models.ColumnBoard{BoardID: board.ID, ColumnName: "To Do"}
+	ranked := models.ColumnBoard{BoardID: board.ID, ColumnName: "Done"}
+	DB.Create(&pending)
+	DB.Create(&ranked)
+
+	//the rank column was added, but the start stopped before the backfill
+	//reached some of the tasks
+	create := func(column models.ColumnBoard, key string) models.Task {
+		task := models.Task{ColumnBoardID: column.ID, Title: "Task", Rank: key}
+		DB.Create(&task)
+		return task
+	}
+	unranked := create(pending, "")
+	second := create(pending, "r")
+	first := create(pending, "i")
+	null := create(pending, "")
+	DB.Model(&null).UpdateColumn("rank_key", gorm.Expr("NULL"))
+	done := create(ranked, "x")
+
+	for range 2 {
+		if err := addTaskRanks(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []uint
+	DB.Model(&models.Task{}).Where("column_board_id = ?", pending.ID).Order("rank_key").Pluck("id", &ids)
+	if want := []uint{first.ID, second.ID, unranked.ID, null.ID}; !slices.Equal(ids, want) {
+		t.Errorf("order = %v, want %v", ids, want)
+	}
+	DB.First(&done, done.ID)
+	if done.Rank != "x" {
+		t.Errorf("rank of a fully ranked column = %q, want it unchanged", done.Rank)
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"gofiber/keyring"
+	"gofiber/models"
+
+	"gorm.io/gorm"
+)
+
+// two-factor secrets used to be stored in clear text, widen the column for
+// ciphertext and encrypt the existing rows
+func addTwoFactor() error {
+	if err := createTables(&models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginChallenge{}); err != nil {
+		return err
+	}
+	if err := widenColumn(&models.TwoFactor{}, "Secret", "secret", 255); err != nil {
+		return err
+	}
+
+	_, err := rewriteTwoFactorSecrets(DB.Where("secret NOT LIKE ?", "enc:%"))
+	return err
+}
+
+// ReencryptTwoFactorSecrets encrypts every two-factor secret that is not
+// encrypted with the active key yet. It returns the number of updated rows.
+func ReencryptTwoFactorSecrets() (int, error) {
+	activePrefix := fmt.Sprintf("enc:v1:%s:%%", keyring.Default.ActiveKeyID())
+	return rewriteTwoFactorSecrets(DB.Where("secret NOT LIKE ?", activePrefix))
+}
+
+// saves the secret of the rows matching query again, which encrypts it with
+// the active key
+func rewriteTwoFactorSecrets(query *gorm.DB) (int, error) {
+	updated := 0
+	twoFactors := []models.TwoFactor{}
+	result := query.Unscoped().
+		Where("secret IS NOT NULL").
+		FindInBatches(&twoFactors, idCardBatchSize, func(tx *gorm.DB, batch int) error {
+			return DB.Transaction(func(tx *gorm.DB) error {
+				for _, twoFactor := range twoFactors {
+					err := tx.Model(&twoFactor).Unscoped().
+						Select("Secret").
+						Updates(&twoFactor).Error
+					if err != nil {
+						return err
+					}
+					updated++
+				}
+				return nil
+			})
+		})
+	return updated, result.Error
+}
@@ -0,0 +1,230 @@
+// Package keyring encrypts sensitive columns with envelope encryption.
+//
+// Every value gets its own random data key. The value is sealed with the data
+// key and the data key is sealed with the active key of the ring, both with
+// AES-256-GCM. The id of the key that sealed the data key is stored with the
+// value, so older keys can stay in the ring for reading while new values are
+// written with the active key:
+//
+//	enc:v1:<key id>:<sealed data key>:<sealed value>
+//
+// Blind indexes are an HMAC of the value under a separate index key. They are
+// deterministic so they can be used for lookups and unique indexes without
+// decrypting rows.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const prefix = "enc:v1:"
+
+const keySize = 32
+
+var keyIDPattern = regexp.MustCompile(`^[a-zA-Z0-9.-]+$`)
+
+var ErrMalformed = errors.New("keyring: malformed ciphertext")
+
+type KeyRing struct {
+	keys     map[string][]byte
+	active   string
+	indexKey []byte
+}
+
+// Default is the key ring used for the IDCard column and two-factor secrets.
+var Default *KeyRing
+
+// New returns a key ring that encrypts with the key active and can decrypt
+// with every key in keys. Keys must be 32 bytes.
+func New(keys map[string][]byte, active string, indexKey []byte) (*KeyRing, error) {
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("keyring: invalid key id %q", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("keyring: key %q must be %d bytes", id, keySize)
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("keyring: active key %q is not in the ring", active)
+	}
+	if len(indexKey) < keySize {
+		return nil, fmt.Errorf("keyring: index key must be at least %d bytes", keySize)
+	}
+	return &KeyRing{keys: keys, active: active, indexKey: indexKey}, nil
+}
+
+// ParseKeys parses a list of keys in the form "id:base64key,id:base64key".
+// The ids are returned in the order they were given.
+func ParseKeys(spec string) (map[string][]byte, []string, error) {
+	keys := map[string][]byte{}
+	ids := []string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("keyring: key %q must be id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, nil, fmt.Errorf("keyring: key %q is not base64", id)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, nil, fmt.Errorf("keyring: duplicated key id %q", id)
+		}
+		keys[id] = key
+		ids = append(ids, id)
+	}
+	return keys, ids, nil
+}
+
+// ActiveKeyID returns the id of the key new values are encrypted with.
+func (k *KeyRing) ActiveKeyID() string {
+	return k.active
+}
+
+// KeyIDs returns the ids of every key in the ring.
+func (k *KeyRing) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID returns the id of the key that encrypted ciphertext.
+func KeyID(ciphertext string) (string, error) {
+	parts, err := split(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return parts[0], nil
+}
+
+// Encrypt seals plaintext with a new data key wrapped by the active key.
+func (k *KeyRing) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealedKey, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.active + ":" +
+		base64.RawURLEncoding.EncodeToString(sealedKey) + ":" +
+		base64.RawURLEncoding.EncodeToString(sealedValue), nil
+}
+
+// Decrypt opens a value produced by Encrypt with any key of the ring.
+func (k *KeyRing) Decrypt(ciphertext string) (string, error) {
+	parts, err := split(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	keyID := parts[0]
+	key, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("keyring: unknown key %q", keyID)
+	}
+
+	sealedKey, errKey := base64.RawURLEncoding.DecodeString(parts[1])
+	sealedValue, errValue := base64.RawURLEncoding.DecodeString(parts[2])
+	if errKey != nil || errValue != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := open(key, sealedKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, sealedValue, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns a deterministic keyed hash of value. It does not change
+// when the encryption keys are rotated.
+func (k *KeyRing) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// returns key id, sealed data key and sealed value
+func split(ciphertext string) ([]string, error) {
+	if !IsEncrypted(ciphertext) {
+		return nil, ErrMalformed
+	}
+	parts := strings.Split(strings.TrimPrefix(ciphertext, prefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	return parts, nil
+}
+
+// AES-256-GCM, the nonce is prepended to the result
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New("keyring: decryption failed")
+	}
+	return plaintext, nil
+}
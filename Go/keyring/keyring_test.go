@@ -0,0 +1,155 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var indexKey = bytes.Repeat([]byte{'i'}, keySize)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func newRing(t *testing.T, keys map[string][]byte, active string) *KeyRing {
+	t.Helper()
+	ring, err := New(keys, active, indexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ring := newRing(t, map[string][]byte{"k1": testKey(1)}, "k1")
+
+	ciphertext, err := ring.Encrypt("1234567890123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(ciphertext) || strings.Contains(ciphertext, "1234567890123") {
+		t.Fatalf("Encrypt() = %q", ciphertext)
+	}
+	if id, _ := KeyID(ciphertext); id != "k1" {
+		t.Errorf("KeyID() = %q, want k1", id)
+	}
+
+	//every value gets its own data key
+	again, _ := ring.Encrypt("1234567890123")
+	if again == ciphertext {
+		t.Error("Encrypt() returned the same ciphertext twice")
+	}
+
+	plaintext, err := ring.Decrypt(ciphertext)
+	if err != nil || plaintext != "1234567890123" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldRing := newRing(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	oldValue, err := oldRing.Encrypt("1234567890123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a new active key, the old one stays for reading
+	rotated := newRing(t, map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, "k2")
+	if plaintext, err := rotated.Decrypt(oldValue); err != nil || plaintext != "1234567890123" {
+		t.Errorf("Decrypt() of a value of the old key = %q, %v", plaintext, err)
+	}
+	newValue, err := rotated.Encrypt("1234567890123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyID(newValue); id != "k2" {
+		t.Errorf("KeyID() after rotation = %q, want k2", id)
+	}
+
+	//once the old key is removed only re-encrypted values can be read
+	retired := newRing(t, map[string][]byte{"k2": testKey(2)}, "k2")
+	if _, err := retired.Decrypt(oldValue); err == nil {
+		t.Error("Decrypt() succeeded without the key of the value")
+	}
+	if plaintext, err := retired.Decrypt(newValue); err != nil || plaintext != "1234567890123" {
+		t.Errorf("Decrypt() of a re-encrypted value = %q, %v", plaintext, err)
+	}
+
+	//the blind index does not depend on the encryption keys
+	if oldRing.BlindIndex("1234567890123") != retired.BlindIndex("1234567890123") {
+		t.Error("BlindIndex() changed with the rotation")
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	ring := newRing(t, map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, "k1")
+	ciphertext, err := ring.Encrypt("1234567890123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(ciphertext, prefix), ":")
+
+	sealedValue, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	sealedValue[len(sealedValue)-1] ^= 1
+
+	tests := map[string]string{
+		"plain text":      "1234567890123",
+		"missing part":    prefix + parts[0] + ":" + parts[1],
+		"unknown key":     prefix + "k3:" + parts[1] + ":" + parts[2],
+		"other key id":    prefix + "k2:" + parts[1] + ":" + parts[2],
+		"flipped bit":     prefix + parts[0] + ":" + parts[1] + ":" + base64.RawURLEncoding.EncodeToString(sealedValue),
+		"invalid base64":  prefix + parts[0] + ":" + parts[1] + ":***",
+		"truncated value": prefix + parts[0] + ":" + parts[1] + ":AAAA",
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if plaintext, err := ring.Decrypt(value); err == nil {
+				t.Errorf("Decrypt() = %q, want an error", plaintext)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     map[string][]byte
+		active   string
+		indexKey []byte
+		wantErr  bool
+	}{
+		{"valid", map[string][]byte{"k1": testKey(1)}, "k1", indexKey, false},
+		{"active key missing", map[string][]byte{"k1": testKey(1)}, "k2", indexKey, true},
+		{"short key", map[string][]byte{"k1": testKey(1)[:16]}, "k1", indexKey, true},
+		{"invalid key id", map[string][]byte{"k:1": testKey(1)}, "k:1", indexKey, true},
+		{"short index key", map[string][]byte{"k1": testKey(1)}, "k1", indexKey[:16], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.keys, tt.active, tt.indexKey); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	keys, ids, err := ParseKeys("k1:" + k1 + ", k2:" + k2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "k1" || ids[1] != "k2" || !bytes.Equal(keys["k2"], testKey(2)) {
+		t.Errorf("ParseKeys() = %v, %v", keys, ids)
+	}
+
+	for _, spec := range []string{"k1", "k1:not base64!", "k1:" + k1 + ",k1:" + k2} {
+		if _, _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) succeeded", spec)
+		}
+	}
+}
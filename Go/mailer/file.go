@@ -0,0 +1,24 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every message as a .eml file in Dir instead of sending
+// it, so mails can be inspected locally.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(message Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage(m.From, message), 0o600)
+}
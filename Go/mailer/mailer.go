@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends the emails of the application, like password reset links.
+type Mailer interface {
+	Send(message Message) error
+}
+
+var Default Mailer = NewMemoryMailer()
+
+// MemoryMailer keeps every message in memory, for local development and
+// tests without a mail server.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// New returns the mailer for kind, one of smtp, file or memory.
+func New(kind string, smtp SMTPMailer, dir string) (Mailer, error) {
+	switch kind {
+	case "smtp":
+		return smtp, nil
+	case "file":
+		return FileMailer{Dir: dir, From: smtp.From}, nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", kind)
+}
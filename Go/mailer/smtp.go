@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.From, []string{message.To}, buildMessage(m.From, message))
+}
+
+// strips line breaks so header values can not inject extra headers
+var headerValue = strings.NewReplacer("\r", "", "\n", "")
+
+// builds a plain text RFC 5322 message
+func buildMessage(from string, message Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", headerValue.Replace(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", headerValue.Replace(message.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(message.Body)
+	return buf.Bytes()
+}
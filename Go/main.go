@@ -1,22 +1,78 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"gofiber/config"
 	"gofiber/database"
+	"gofiber/keyring"
+	"gofiber/mailer"
+	"gofiber/middleware"
 	"gofiber/routes"
+	"gofiber/validation"
+	"os"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func setupRoutes(app *fiber.App) {
-	//users endpoints
+	//public endpoints
 	app.Post("/api/users", routes.CreateUser)
+	app.Post("/api/login", routes.CreateLogin)
+	app.Post("/api/login/refresh", routes.RefreshLogin)
+	app.Post("/api/login/2fa", routes.CompleteLoginChallenge)
+	app.Get("/api/oidc/login", routes.OIDCLogin)
+	app.Get("/api/oidc/callback", routes.OIDCCallback)
+	app.Post("/api/password/forgot", routes.ForgotPassword)
+	app.Post("/api/password/reset", routes.ResetPassword)
+	app.Post("/api/users/verify", routes.VerifyEmail)
+
+	//every endpoint registered below requires an access token
+	app.Use("/api", middleware.Protected())
+
+	//sessions endpoints
+	app.Post("/api/logout", middleware.SessionOnly(), routes.Logout)
+	app.Get("/api/sessions", middleware.SessionOnly(), routes.GetSessions)
+	app.Delete("/api/sessions", middleware.SessionOnly(), routes.DeleteSessions)
+	app.Delete("/api/sessions/:id", middleware.SessionOnly(), routes.DeleteSession)
+
+	//two-factor authentication endpoints
+	app.Post("/api/2fa/enroll", middleware.SessionOnly(), routes.EnrollTwoFactor)
+	app.Post("/api/2fa/confirm", middleware.SessionOnly(), routes.ConfirmTwoFactor)
+	app.Post("/api/2fa/recovery-codes", middleware.SessionOnly(), routes.RegenerateRecoveryCodes)
+	app.Delete("/api/2fa", middleware.SessionOnly(), routes.DisableTwoFactor)
+
+	//personal access tokens endpoints
+	app.Post("/api/tokens", middleware.SessionOnly(), routes.CreatePersonalAccessToken)
+	app.Get("/api/tokens", middleware.SessionOnly(), routes.GetPersonalAccessTokens)
+	app.Delete("/api/tokens/:id", middleware.SessionOnly(), routes.DeletePersonalAccessToken)
+
+	//login history endpoints
+	app.Get("/api/login/attempts", routes.GetLoginAttempts)
+
+	//admin endpoints
+	admin := app.Group("/api/admin", middleware.SessionOnly(), middleware.AdminOnly())
+	admin.Get("/users", routes.GetAdminUsers)
+	admin.Get("/users/invalid-id-cards", routes.GetInvalidIDCards)
+	admin.Put("/users/:id/admin", routes.SetUserAdmin)
+	admin.Post("/users/:id/deactivate", routes.DeactivateUser)
+	admin.Post("/users/:id/reactivate", routes.ReactivateUser)
+	admin.Post("/users/:id/reset-credentials", routes.ResetUserCredentials)
+	admin.Post("/users/:id/impersonate", routes.ImpersonateUser)
+	admin.Get("/boards", routes.GetAdminBoards)
+	admin.Post("/boards/:id/owner", routes.ChangeBoardOwner)
+	admin.Get("/lockouts", routes.GetLoginLockouts)
+	admin.Post("/lockouts/:id/unlock", routes.UnlockLoginLockout)
+	admin.Get("/audit", routes.GetAuditLogs)
+
+	//users endpoints
+	app.Post("/api/users/verify/resend", routes.ResendVerificationEmail)
 	app.Get("/api/users", routes.GetUsers)
 	app.Get("/api/users/:id", routes.GetUserByID)
-	app.Put("/api/users/:id", routes.UpdateUser)
-	app.Delete("/api/users/:id", routes.DeleteUser)
-
-	//login endpoints
-	app.Post("/api/login", routes.CreateLogin)
+	app.Put("/api/users/:id", middleware.SessionOnly(), routes.UpdateUser)
+	app.Delete("/api/users/:id", middleware.SessionOnly(), routes.DeleteUser)
 
 	//boards endpoints
 	app.Post("/api/boards", routes.CreateBoard)
@@ -24,20 +80,29 @@ func setupRoutes(app *fiber.App) {
 	app.Get("/api/boards/:id", routes.GetBoardByID)
 	app.Put("/api/boards/:id", routes.UpdateBoard)
 	app.Delete("/api/boards/:id", routes.DeleteBoard)
+	app.Post("/api/boards/:id/transfer", middleware.SessionOnly(), routes.TransferBoard)
 
-	//boardmembers endpoints
-	app.Post("/api/boardmembers", routes.CreateBoardMember)
+	//boardmembers endpoints, members join through an invitation
 	app.Get("/api/boardmembers", routes.GetBoardMembers)
 	app.Get("/api/boardmembers/:id", routes.GetBoardMemberByID)
 	app.Put("/api/boardmembers/:id", routes.UpdateBoardMember)
 	app.Delete("/api/boardmembers/:id", routes.DeleteBoardMember)
 
+	//boardinvitations endpoints
+	app.Post("/api/boardinvitations", routes.CreateBoardInvitation)
+	app.Get("/api/boardinvitations", routes.GetBoardInvitations)
+	app.Get("/api/boardinvitations/:id", routes.GetBoardInvitationByID)
+	app.Post("/api/boardinvitations/:id/accept", routes.AcceptBoardInvitation)
+	app.Post("/api/boardinvitations/:id/decline", routes.DeclineBoardInvitation)
+	app.Delete("/api/boardinvitations/:id", routes.DeleteBoardInvitation)
+
 	//columnboards endpoints
 	app.Post("/api/columnboards", routes.CreateColumnBoard)
 	app.Get("/api/columnboards", routes.GetColumnBoards)
 	app.Get("/api/columnboards/:id", routes.GetColumnBoardByID)
 	app.Put("/api/columnboards/:id", routes.UpdateColumnBoard)
 	app.Delete("/api/columnboards/:id", routes.DeleteColumnBoard)
+	app.Put("/api/boards/:id/columns/order", routes.ReorderColumnBoards)
 
 	//tasks endpoints
 	app.Post("/api/tasks", routes.CreateTask)
@@ -45,6 +110,7 @@ func setupRoutes(app *fiber.App) {
 	app.Get("/api/tasks/:id", routes.GetTaskByID)
 	app.Put("/api/tasks/:id", routes.UpdateTask)
 	app.Delete("/api/tasks/:id", routes.DeleteTask)
+	app.Post("/api/tasks/:id/move", routes.MoveTask)
 
 	//taskassignees endpoints
 	app.Post("/api/taskassignees", routes.CreateTaskAssignee)
@@ -53,10 +119,142 @@ func setupRoutes(app *fiber.App) {
 	app.Delete("/api/taskassignees/:id", routes.DeleteTaskAssignee)
 }
 
+// loads the key ring of the IDCard column from the configuration
+func setupKeyRing() (*keyring.KeyRing, error) {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, config.App.SecretKey)
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+
+	keys := map[string][]byte{"default": derive("id-card-key")}
+	ids := []string{"default"}
+	if config.App.IDCardKeys != "" {
+		var err error
+		if keys, ids, err = keyring.ParseKeys(config.App.IDCardKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	active := config.App.IDCardActiveKey
+	if active == "" && len(ids) > 0 {
+		active = ids[len(ids)-1]
+	}
+
+	indexKey := derive("id-card-index")
+	if config.App.IDCardIndexKey != "" {
+		var err error
+		if indexKey, err = base64.StdEncoding.DecodeString(config.App.IDCardIndexKey); err != nil {
+			return nil, fmt.Errorf("ID_CARD_INDEX_KEY is not base64: %w", err)
+		}
+	}
+
+	return keyring.New(keys, active, indexKey)
+}
+
+// loads the password policy from the configuration
+func setupPasswordPolicy() error {
+	policy := validation.PasswordPolicy{
+		MinLength:     config.App.PasswordMinLength,
+		MinClasses:    config.App.PasswordMinClasses,
+		CheckUsername: config.App.PasswordCheckUsername,
+		History:       config.App.PasswordHistory,
+	}
+	if config.App.PasswordBreachedFile != "" {
+		if err := policy.LoadBreachedPasswords(config.App.PasswordBreachedFile); err != nil {
+			return err
+		}
+	}
+	validation.DefaultPasswordPolicy = policy
+	return nil
+}
+
+// re-encrypts the IDCard column and the two-factor secrets with the active
+// key, run it after adding a new key and before removing the old one from
+// ID_CARD_KEYS
+func reencryptIDCards() {
+	updated, err := database.ReencryptIDCards()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("re-encrypted %d id cards with key %q\n", updated, keyring.Default.ActiveKeyID())
+
+	updated, err = database.ReencryptTwoFactorSecrets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("re-encrypted %d two-factor secrets with key %q\n", updated, keyring.Default.ActiveKeyID())
+}
+
+// promotes a user to administrator from the command line
+func grantAdmin(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: grant-admin <username>")
+		os.Exit(2)
+	}
+	if err := database.GrantAdmin(args[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is now an administrator\n", args[0])
+}
+
 func main() {
+	config.Load()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ring, err := setupKeyRing()
+	if err != nil {
+		panic(err)
+	}
+	keyring.Default = ring
+
+	if err := setupPasswordPolicy(); err != nil {
+		panic(err)
+	}
+
 	database.ConnectDB()
 
+	//command line subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reencrypt-id-cards":
+			reencryptIDCards()
+		case "grant-admin":
+			grantAdmin(os.Args[2:])
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+		return
+	}
+
+	//the account to promote must exist before the first start with it
+	if err := database.BootstrapAdmin(config.App.BootstrapAdmin); err != nil {
+		fmt.Fprintln(os.Stderr, "admin bootstrap:", err)
+		os.Exit(1)
+	}
+
+	mail, err := mailer.New(config.App.Mailer, mailer.SMTPMailer{
+		Host:     config.App.SMTPHost,
+		Port:     config.App.SMTPPort,
+		Username: config.App.SMTPUsername,
+		Password: config.App.SMTPPassword,
+		From:     config.App.MailFrom,
+	}, config.App.MailDir)
+	if err != nil {
+		panic(err)
+	}
+	mailer.Default = mail
+
 	app := fiber.New()
+	app.Use(middleware.SecurityHeaders())
+	app.Use(middleware.CORS())
 	setupRoutes(app)
 
 	app.Listen(":8000")
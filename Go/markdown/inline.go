@@ -0,0 +1,328 @@
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// the link of every URL written by the renderer
+const linkRel = `rel="nofollow noopener noreferrer"`
+
+// emphasis and links must close within this many bytes, which keeps
+// rendering linear on text full of unclosed delimiters
+const maxSpan = 1000
+
+// delimiters of emphasis, the longer ones are tried first
+var emphasis = []struct {
+	delimiter string
+	tag       string
+}{
+	{"**", "strong"},
+	{"__", "strong"},
+	{"~~", "del"},
+	{"*", "em"},
+	{"_", "em"},
+}
+
+// renders the inline syntax of text. inLink is set for the label of a
+// link, which can not contain other links.
+func (r *renderer) inline(text string, inLink bool) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		if n := r.inlineAt(&out, text, i, inLink); n > 0 {
+			i += n
+			continue
+		}
+		out.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// writes the syntax starting at text[i] and returns its length, or 0 when
+// there is none
+func (r *renderer) inlineAt(out *strings.Builder, text string, i int, inLink bool) int {
+	switch c := text[i]; {
+	case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+		out.WriteString(html.EscapeString(text[i+1 : i+2]))
+		return 2
+
+	case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+		out.WriteString("<br>\n")
+		return 2
+
+	case c == ' ':
+		//two spaces at the end of a line are a line break
+		n := 1
+		for i+n < len(text) && text[i+n] == ' ' {
+			n++
+		}
+		if i+n < len(text) && text[i+n] == '\n' {
+			if n >= 2 {
+				out.WriteString("<br>\n")
+			} else {
+				out.WriteString("\n")
+			}
+			return n + 1
+		}
+		out.WriteString(text[i : i+n])
+		return n
+
+	case c == '`':
+		n := runLength(text, i, '`')
+		end := closingRun(text, i+n, '`', n)
+		if end < 0 {
+			out.WriteString(text[i : i+n])
+			return n
+		}
+		code := strings.ReplaceAll(text[i+n:end], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return end + n - i
+
+	case c == '[' && !inLink:
+		label, destination, n := parseLink(text[i:])
+		if n == 0 {
+			return 0
+		}
+		if href, ok := safeURL(destination); ok {
+			out.WriteString(`<a href="` + html.EscapeString(href) + `" ` + linkRel + `>` + r.inline(label, true) + "</a>")
+		} else {
+			out.WriteString(r.inline(label, true))
+		}
+		return n
+
+	case c == '<' && !inLink:
+		end := strings.IndexAny(text[i+1:], "<> \n")
+		if end < 0 || text[i+1+end] != '>' {
+			return 0
+		}
+		destination := text[i+1 : i+1+end]
+		if !strings.Contains(destination, ":") && strings.Contains(destination, "@") {
+			destination = "mailto:" + destination
+		}
+		href, ok := safeURL(destination)
+		if !ok || !strings.Contains(destination, ":") {
+			return 0
+		}
+		out.WriteString(`<a href="` + html.EscapeString(href) + `" ` + linkRel + `>` + html.EscapeString(text[i+1:i+1+end]) + "</a>")
+		return end + 2
+
+	case c == 'h' && !inLink && wordStart(text, i):
+		url := bareURL(text[i:])
+		if url == "" {
+			return 0
+		}
+		out.WriteString(`<a href="` + html.EscapeString(url) + `" ` + linkRel + `>` + html.EscapeString(url) + "</a>")
+		return len(url)
+
+	case c == '#' && !inLink && wordStart(text, i):
+		n := 1
+		for i+n < len(text) && n <= 10 && text[i+n] >= '0' && text[i+n] <= '9' {
+			n++
+		}
+		if n == 1 || n > 10 || (i+n < len(text) && (isAlnum(text[i+n]) || text[i+n] == '_')) {
+			return 0
+		}
+		if id, err := strconv.ParseUint(text[i+1:i+n], 10, 32); err != nil || id == 0 {
+			return 0
+		}
+		//linked or not by Document.HTML
+		out.WriteString(referenceMark + text[i+1:i+n] + referenceMark)
+		return n
+
+	case c == '*' || c == '_' || c == '~':
+		return r.emphasisAt(out, text, i, inLink)
+	}
+	return 0
+}
+
+func (r *renderer) emphasisAt(out *strings.Builder, text string, i int, inLink bool) int {
+	for _, e := range emphasis {
+		d := e.delimiter
+		open := i + len(d)
+		if !strings.HasPrefix(text[i:], d) || open >= len(text) || isSpace(text[open]) {
+			continue
+		}
+		//a single delimiter must not be part of a longer run
+		if len(d) == 1 && text[open] == d[0] {
+			continue
+		}
+		//_ inside words, as in snake_case, is not emphasis
+		if d[0] == '_' && i > 0 && isAlnum(text[i-1]) {
+			continue
+		}
+
+		//delimiters that open emphasis inside are closed first
+		nested := 0
+		limit := min(len(text), open+maxSpan)
+		for search := open; search < limit; {
+			end := strings.Index(text[search:limit], d)
+			if end < 0 {
+				break
+			}
+			end += search
+			search = end + len(d)
+			if isSpace(text[end-1]) {
+				if end+len(d) < len(text) && !isSpace(text[end+len(d)]) {
+					nested++
+				}
+				continue
+			}
+			if end == open {
+				continue
+			}
+			if nested > 0 {
+				nested--
+				continue
+			}
+			if len(d) == 1 && (text[end-1] == d[0] || (end+1 < len(text) && text[end+1] == d[0])) {
+				continue
+			}
+			if d[0] == '_' && end+len(d) < len(text) && isAlnum(text[end+len(d)]) {
+				continue
+			}
+			out.WriteString("<" + e.tag + ">" + r.inline(text[open:end], inLink) + "</" + e.tag + ">")
+			return end + len(d) - i
+		}
+	}
+	return 0
+}
+
+// parses [label](destination "title") and returns its length, the title is
+// not used
+func parseLink(text string) (string, string, int) {
+	text = text[:min(len(text), 2*maxSpan)]
+	if !strings.Contains(text, "](") {
+		return "", "", 0
+	}
+	depth := 0
+	labelEnd := -1
+	for i := 0; i < min(len(text), maxSpan) && labelEnd < 0; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				labelEnd = i
+			}
+		}
+	}
+	if labelEnd < 0 || labelEnd+1 >= len(text) || text[labelEnd+1] != '(' {
+		return "", "", 0
+	}
+
+	depth = 0
+	for i := labelEnd + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '\n':
+			return "", "", 0
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				fields := strings.Fields(text[labelEnd+2 : i])
+				destination := ""
+				if len(fields) > 0 {
+					destination = strings.TrimSuffix(strings.TrimPrefix(fields[0], "<"), ">")
+				}
+				return text[1:labelEnd], destination, i + 1
+			}
+		}
+	}
+	return "", "", 0
+}
+
+// returns the http or https URL at the start of text, without the
+// punctuation that usually follows a URL in a sentence
+func bareURL(text string) string {
+	if !strings.HasPrefix(text, "http://") && !strings.HasPrefix(text, "https://") {
+		return ""
+	}
+	end := strings.IndexAny(text, " \n<>\"`")
+	if end < 0 {
+		end = len(text)
+	}
+	url := strings.TrimRight(text[:end], ".,:;!?'*_~")
+	if strings.HasSuffix(url, ")") && strings.Count(url, "(") < strings.Count(url, ")") {
+		url = url[:len(url)-1]
+	}
+	if strings.HasSuffix(url, "://") {
+		return ""
+	}
+	return url
+}
+
+// safeURL returns the URL to link to, or false for schemes that can run
+// code such as javascript: or data:
+func safeURL(destination string) (string, bool) {
+	//browsers ignore control characters and whitespace in URLs
+	url := strings.Map(func(c rune) rune {
+		if c <= ' ' || c == 0x7f {
+			return -1
+		}
+		return c
+	}, destination)
+	if url == "" {
+		return "", false
+	}
+
+	if i := strings.IndexAny(url, ":/?#"); i >= 0 && url[i] == ':' {
+		switch strings.ToLower(url[:i]) {
+		case "http", "https", "mailto":
+		default:
+			return "", false
+		}
+	}
+	return url, true
+}
+
+func runLength(text string, i int, c byte) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// index of the next run of exactly n c after from, or -1
+func closingRun(text string, from int, c byte, n int) int {
+	for i := from; i < len(text); {
+		if text[i] != c {
+			i++
+			continue
+		}
+		run := runLength(text, i, c)
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// whether a word can start at text[i]
+func wordStart(text string, i int) bool {
+	return i == 0 || !(isAlnum(text[i-1]) || strings.IndexByte("_&#/", text[i-1]) >= 0)
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\t'
+}
+
+func isPunct(c byte) bool {
+	return c < 128 && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
@@ -0,0 +1,160 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+type listMarker struct {
+	ordered bool
+	//-, * or + for bullets, . or ) for numbers
+	delimiter byte
+	start     int
+	//indentation of the content of the item
+	width int
+}
+
+func markerOf(line string) (listMarker, bool) {
+	text, ok := blockStart(line)
+	if !ok || text == "" {
+		return listMarker{}, false
+	}
+	indent := len(line) - len(text)
+
+	marker := listMarker{}
+	n := 0
+	if strings.IndexByte("-*+", text[0]) >= 0 {
+		marker.delimiter = text[0]
+		n = 1
+	} else {
+		for n < len(text) && n < 9 && text[n] >= '0' && text[n] <= '9' {
+			n++
+		}
+		if n == 0 || n == len(text) || (text[n] != '.' && text[n] != ')') {
+			return listMarker{}, false
+		}
+		marker.ordered = true
+		marker.delimiter = text[n]
+		marker.start, _ = strconv.Atoi(text[:n])
+		n++
+	}
+
+	//the marker is followed by a space or the end of the line
+	if n < len(text) && text[n] != ' ' {
+		return listMarker{}, false
+	}
+	spaces := indentOf(text[n:])
+	if spaces == 0 || spaces > 4 || n+spaces == len(text) {
+		spaces = 1
+	}
+	marker.width = indent + n + spaces
+	return marker, true
+}
+
+func isListItem(line string) bool {
+	_, ok := markerOf(line)
+	return ok
+}
+
+func (m listMarker) sameList(other listMarker) bool {
+	return m.ordered == other.ordered && m.delimiter == other.delimiter
+}
+
+func (r *renderer) list(lines []string, i int) int {
+	first, _ := markerOf(lines[i])
+	items := [][]string{}
+	loose := false
+
+	for i < len(lines) {
+		marker, ok := markerOf(lines[i])
+		if !ok || !marker.sameList(first) {
+			break
+		}
+		item := []string{""}
+		if len(lines[i]) > marker.width {
+			item[0] = lines[i][marker.width:]
+		}
+
+		//the lines of the item are indented to its content, except for
+		//lazy continuation lines of a paragraph
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				next := i
+				for next < len(lines) && isBlank(lines[next]) {
+					next++
+				}
+				if next == len(lines) || indentOf(lines[next]) < marker.width {
+					break
+				}
+				loose = true
+				for ; i < next; i++ {
+					item = append(item, "")
+				}
+				i--
+				continue
+			}
+			if indentOf(line) >= marker.width {
+				item = append(item, dedent(line, marker.width))
+				continue
+			}
+			if interruptsParagraph(line) || isBlank(item[len(item)-1]) {
+				break
+			}
+			item = append(item, strings.TrimLeft(line, " "))
+		}
+		items = append(items, item)
+
+		//blank lines between items make the list loose
+		next := i
+		for next < len(lines) && isBlank(lines[next]) {
+			next++
+		}
+		if next > i && next < len(lines) {
+			if marker, ok := markerOf(lines[next]); ok && marker.sameList(first) {
+				loose = true
+				i = next
+			}
+		}
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	r.out.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		r.out.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	r.out.WriteString(">\n")
+	for _, item := range items {
+		r.listItem(item, loose)
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func (r *renderer) listItem(item []string, loose bool) {
+	checkbox := ""
+	if text := item[0]; len(text) >= 3 && text[0] == '[' && text[2] == ']' &&
+		(len(text) == 3 || text[3] == ' ') && strings.IndexByte(" xX", text[1]) >= 0 {
+		checkbox = `<input type="checkbox" disabled>`
+		if text[1] != ' ' {
+			checkbox = `<input type="checkbox" checked disabled>`
+		}
+		item[0] = strings.TrimPrefix(text[3:], " ")
+	}
+
+	if checkbox == "" {
+		r.out.WriteString("<li>")
+	} else {
+		r.out.WriteString(`<li class="task-list-item">` + checkbox + " ")
+	}
+	if len(item) == 1 && !loose && !interruptsParagraph(item[0]) {
+		r.out.WriteString(r.inline(strings.TrimSpace(item[0]), false))
+	} else {
+		r.out.WriteString("\n")
+		r.nested(item, !loose)
+	}
+	r.out.WriteString("</li>\n")
+}
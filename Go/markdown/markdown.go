@@ -0,0 +1,338 @@
+// Package markdown renders the Markdown of task descriptions to HTML that
+// can be put into a page as is.
+//
+// Only the HTML produced for the supported syntax is ever written: text and
+// raw HTML in the source are escaped, so scripts, event handlers and style
+// attributes can not get through. Links are only kept for http, https and
+// mailto URLs and relative paths.
+//
+// Supported are paragraphs, headings, emphasis, strikethrough, inline code,
+// fenced code blocks, block quotes, rules, ordered and unordered lists with
+// [ ] and [x] checkboxes, links, autolinks and references to other tasks
+// written as #id.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// deeper block quotes and lists are rendered as text
+const maxDepth = 16
+
+type Options struct {
+	// TaskLink returns the URL a #id reference links to, or an empty
+	// string when id is not a task the reader can see, the reference is
+	// left as text then. References are not linked without TaskLink.
+	TaskLink func(id uint) string
+}
+
+type renderer struct {
+	out   strings.Builder
+	depth int
+	//paragraphs of tight list items are not wrapped in <p>
+	tight bool
+}
+
+// surrounds the digits of a #id reference in the output of the renderer. the
+// source can not contain it, NUL is replaced while splitting the lines.
+const referenceMark = "\x00"
+
+// Document is rendered Markdown whose #id references are linked on output,
+// so the tasks referenced by many documents can be looked up at once.
+type Document struct {
+	//HTML with the references in between, parts[1], parts[3]... are the
+	//digits of a reference
+	parts []string
+}
+
+// Parse renders source.
+func Parse(source string) *Document {
+	r := &renderer{}
+	r.blocks(splitLines(source))
+	return &Document{parts: strings.Split(r.out.String(), referenceMark)}
+}
+
+// Render returns the HTML of source.
+func Render(source string, opts Options) string {
+	return Parse(source).HTML(opts.TaskLink)
+}
+
+// References returns the ids of the tasks referenced by source, in the order
+// they appear. References inside code are not counted.
+func References(source string) []uint {
+	return Parse(source).References()
+}
+
+// References returns the ids of the tasks referenced by the document, in the
+// order they appear.
+func (d *Document) References() []uint {
+	ids := []uint{}
+	seen := map[uint]bool{}
+	for i := 1; i < len(d.parts); i += 2 {
+		if id := referenceID(d.parts[i]); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// HTML returns the document with its references linked by taskLink, see
+// Options.
+func (d *Document) HTML(taskLink func(id uint) string) string {
+	var out strings.Builder
+	for i, part := range d.parts {
+		if i%2 == 0 {
+			out.WriteString(part)
+			continue
+		}
+
+		id := strconv.FormatUint(uint64(referenceID(part)), 10)
+		href := ""
+		if taskLink != nil {
+			href = taskLink(referenceID(part))
+		}
+		if href == "" {
+			out.WriteString("#" + part)
+			continue
+		}
+		out.WriteString(`<a href="` + html.EscapeString(href) + `" class="task-link" data-task-id="` +
+			id + `">#` + id + "</a>")
+	}
+	return out.String()
+}
+
+// the digits were checked by the renderer
+func referenceID(digits string) uint {
+	id, _ := strconv.ParseUint(digits, 10, 32)
+	return uint(id)
+}
+
+func splitLines(source string) []string {
+	source = strings.ToValidUTF8(source, "�")
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+	return lines
+}
+
+// leading tabs count as 4 spaces for the indentation
+func expandTabs(line string) string {
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	if !strings.Contains(line[:n], "\t") {
+		return line
+	}
+	return strings.ReplaceAll(line[:n], "\t", "    ") + line[n:]
+}
+
+func (r *renderer) blocks(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case isFence(line):
+			i = r.codeBlock(lines, i)
+		case isRule(line):
+			r.out.WriteString("<hr>\n")
+			i++
+		case headingLevel(line) > 0:
+			r.heading(line)
+			i++
+		case isQuote(line) && r.depth < maxDepth:
+			i = r.blockquote(lines, i)
+		case isListItem(line) && r.depth < maxDepth:
+			i = r.list(lines, i)
+		default:
+			i = r.paragraph(lines, i)
+		}
+	}
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// removes up to n spaces of indentation
+func dedent(line string, n int) string {
+	return line[min(n, indentOf(line)):]
+}
+
+// block syntax may be indented by up to 3 spaces
+func blockStart(line string) (string, bool) {
+	if indentOf(line) > 3 {
+		return "", false
+	}
+	return strings.TrimLeft(line, " "), true
+}
+
+// whether line starts a block that ends a paragraph
+func interruptsParagraph(line string) bool {
+	return isBlank(line) || isFence(line) || isRule(line) || headingLevel(line) > 0 ||
+		isQuote(line) || isListItem(line)
+}
+
+func isRule(line string) bool {
+	text, ok := blockStart(line)
+	if !ok || text == "" || !strings.ContainsRune("-*_", rune(text[0])) {
+		return false
+	}
+	count := 0
+	for _, c := range text {
+		if c == rune(text[0]) {
+			count++
+		} else if c != ' ' {
+			return false
+		}
+	}
+	return count >= 3
+}
+
+func headingLevel(line string) int {
+	text, ok := blockStart(line)
+	if !ok {
+		return 0
+	}
+	level := 0
+	for level < len(text) && text[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(text) && text[level] != ' ') {
+		return 0
+	}
+	return level
+}
+
+func (r *renderer) heading(line string) {
+	text, _ := blockStart(line)
+	level := headingLevel(line)
+	content := strings.TrimSpace(text[level:])
+
+	//an optional closing sequence of #
+	if trimmed := strings.TrimRight(content, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		content = strings.TrimSpace(trimmed)
+	}
+	fmt.Fprintf(&r.out, "<h%d>%s</h%d>\n", level, r.inline(content, false), level)
+}
+
+func (r *renderer) paragraph(lines []string, i int) int {
+	start := i
+	for i++; i < len(lines) && !interruptsParagraph(lines[i]); i++ {
+	}
+	text := []string{}
+	for _, line := range lines[start:i] {
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+	content := r.inline(strings.TrimRight(strings.Join(text, "\n"), " "), false)
+	if r.tight {
+		r.out.WriteString(content + "\n")
+	} else {
+		r.out.WriteString("<p>" + content + "</p>\n")
+	}
+	return i
+}
+
+func isQuote(line string) bool {
+	text, ok := blockStart(line)
+	return ok && strings.HasPrefix(text, ">")
+}
+
+func (r *renderer) blockquote(lines []string, i int) int {
+	content := []string{}
+	for ; i < len(lines) && isQuote(lines[i]); i++ {
+		text, _ := blockStart(lines[i])
+		text = strings.TrimPrefix(text[1:], " ")
+		content = append(content, text)
+	}
+
+	r.out.WriteString("<blockquote>\n")
+	r.nested(content, false)
+	r.out.WriteString("</blockquote>\n")
+	return i
+}
+
+// renders lines as the content of a quote or list item
+func (r *renderer) nested(lines []string, tight bool) {
+	depth, wasTight := r.depth, r.tight
+	r.depth, r.tight = r.depth+1, tight
+	r.blocks(lines)
+	r.depth, r.tight = depth, wasTight
+}
+
+// a fence is 3 or more backticks or tildes
+func fenceOf(line string) (string, string, bool) {
+	text, ok := blockStart(line)
+	if !ok || len(text) < 3 || (text[0] != '`' && text[0] != '~') {
+		return "", "", false
+	}
+	n := 0
+	for n < len(text) && text[n] == text[0] {
+		n++
+	}
+	info := strings.TrimSpace(text[n:])
+	if n < 3 || (text[0] == '`' && strings.Contains(info, "`")) {
+		return "", "", false
+	}
+	return text[:n], info, true
+}
+
+func isFence(line string) bool {
+	_, _, ok := fenceOf(line)
+	return ok
+}
+
+func (r *renderer) codeBlock(lines []string, i int) int {
+	fence, info, _ := fenceOf(lines[i])
+	indent := indentOf(lines[i])
+
+	code := []string{}
+	for i++; i < len(lines); i++ {
+		if closing, rest, ok := fenceOf(lines[i]); ok && rest == "" &&
+			closing[0] == fence[0] && len(closing) >= len(fence) {
+			i++
+			break
+		}
+		code = append(code, dedent(lines[i], indent))
+	}
+
+	r.out.WriteString("<pre><code")
+	if language := languageOf(info); language != "" {
+		r.out.WriteString(` class="language-` + language + `"`)
+	}
+	r.out.WriteString(">")
+	for _, line := range code {
+		r.out.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+	return i
+}
+
+// the first word of the info string, limited to the characters language
+// names use
+func languageOf(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	language := strings.Map(func(c rune) rune {
+		if c < 128 && (isAlnum(byte(c)) || strings.ContainsRune("_+#.-", c)) {
+			return c
+		}
+		return -1
+	}, fields[0])
+	return html.EscapeString(language)
+}
@@ -0,0 +1,177 @@
+package markdown
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// links #7 and #42 only, like a board with these two tasks
+func taskLink(id uint) string {
+	if id == 7 || id == 42 {
+		return "/tasks/" + strconv.FormatUint(uint64(id), 10)
+	}
+	return ""
+}
+
+func TestRender(t *testing.T) {
+	link7 := `<a href="/tasks/7" class="task-link" data-task-id="7">#7</a>`
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"javascript link mixed case", "[x](JaVaScRiPt:alert(1))", "<p>x</p>\n"},
+		{"javascript link leading space", "[x]( javascript:alert(1))", "<p>x</p>\n"},
+		{"javascript link control character", "[x](java\x01script:alert(1))", "<p>x</p>\n"},
+		{"javascript link entity", "[x](java&#9;script:alert(1))", `<p><a href="java&amp;#9;script:alert(1)" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"javascript link newline", "[x](java\nscript:alert(1))", "<p>[x](java\nscript:alert(1))</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"data link", "[x](data:text/html,<script>alert(1)</script>)", "<p>x</p>\n"},
+		{"data link upper case", "[x](DATA:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>\n"},
+		{"vbscript link", "[x](vbscript:msgbox)", "<p>x</p>\n"},
+		{"https link", "[x](https://a.com)", `<p><a href="https://a.com" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"relative link", "[x](/tasks/1)", `<p><a href="/tasks/1" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"mailto autolink", "<me@a.com>", `<p><a href="mailto:me@a.com" rel="nofollow noopener noreferrer">me@a.com</a></p>` + "\n"},
+		{"quote in link", `[x](http://a.com/"onmouseover="alert(1))`, `<p><a href="http://a.com/&#34;onmouseover=&#34;alert(1)" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"quote in bare URL", `https://a.com/?a="b`, `<p><a href="https://a.com/?a=" rel="nofollow noopener noreferrer">https://a.com/?a=</a>&#34;b</p>` + "\n"},
+		{"quote in label", `[a" onclick="x](https://a.com)`, `<p><a href="https://a.com" rel="nofollow noopener noreferrer">a&#34; onclick=&#34;x</a></p>` + "\n"},
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"event handler", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n"},
+		{"checkboxes", "- [ ] todo\n- [x] done", "<ul>\n" +
+			`<li class="task-list-item"><input type="checkbox" disabled> todo</li>` + "\n" +
+			`<li class="task-list-item"><input type="checkbox" checked disabled> done</li>` + "\n</ul>\n"},
+		{"code block", "```js\n<b>#7</b>\n```", `<pre><code class="language-js">&lt;b&gt;#7&lt;/b&gt;` + "\n</code></pre>\n"},
+		{"code block language", "```\" onclick=\"x\na\n```", "<pre><code>a\n</code></pre>\n"},
+		{"inline code", "`#7`", "<p><code>#7</code></p>\n"},
+		{"reference", "see #7 and #8", "<p>see " + link7 + " and #8</p>\n"},
+		{"reference leading zeros", "#007", "<p>" + link7 + "</p>\n"},
+		{"reference zero", "#0", "<p>#0</p>\n"},
+		{"reference too large", "#99999999999", "<p>#99999999999</p>\n"},
+		{"reference in word", "a#7 #7a #7_", "<p>a#7 #7a #7_</p>\n"},
+		{"reference in link", "[#7](https://a.com)", `<p><a href="https://a.com" rel="nofollow noopener noreferrer">#7</a></p>` + "\n"},
+		{"reference in heading and emphasis", "# #7 *#7*", "<h1>" + link7 + " <em>" + link7 + "</em></h1>\n"},
+		{"NUL", "#\x007\x00", "<p>#�7�</p>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.source, Options{TaskLink: taskLink}); got != tt.want {
+			t.Errorf("%s: Render(%q) = %q, want %q", tt.name, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestRenderWithoutTaskLink(t *testing.T) {
+	if got, want := Render("#7 #007", Options{}), "<p>#7 #007</p>\n"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []uint
+	}{
+		{"", []uint{}},
+		{"#7", []uint{7}},
+		{"#42 #7 #42 #007", []uint{42, 7}},
+		{"`#7` [#8](https://a.com) #9", []uint{9}},
+		{"```\n#7\n```\n- [ ] #8\n> #9", []uint{8, 9}},
+		{"#0 a#7 #7a", []uint{}},
+	}
+	for _, tt := range tests {
+		if got := References(tt.source); !slices.Equal(got, tt.want) {
+			t.Errorf("References(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	document := Parse("#7 and #8")
+	if got, want := document.References(), []uint{7, 8}; !slices.Equal(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+
+	//a document can be linked after its references were looked up
+	linked := document.HTML(func(id uint) string {
+		if id == 8 {
+			return `/tasks/8?a="b"`
+		}
+		return ""
+	})
+	want := `<p>#7 and <a href="/tasks/8?a=&#34;b&#34;" class="task-link" data-task-id="8">#8</a></p>` + "\n"
+	if linked != want {
+		t.Errorf("HTML() = %q, want %q", linked, want)
+	}
+	if got := document.HTML(nil); got != "<p>#7 and #8</p>\n" {
+		t.Errorf("HTML(nil) = %q", got)
+	}
+}
+
+var (
+	tag  = regexp.MustCompile(`<(/?)([a-z0-9]+)[^>]*>`)
+	href = regexp.MustCompile(`href="([^"]*)"`)
+)
+
+// the elements the renderer opens must be closed again in order
+func balanced(output string) bool {
+	open := []string{}
+	for _, m := range tag.FindAllStringSubmatch(output, -1) {
+		switch name := m[2]; {
+		case name == "br" || name == "hr" || name == "input":
+		case m[1] == "":
+			open = append(open, name)
+		case len(open) == 0 || open[len(open)-1] != name:
+			return false
+		default:
+			open = open[:len(open)-1]
+		}
+	}
+	return len(open) == 0
+}
+
+func FuzzRender(f *testing.F) {
+	for _, seed := range []string{
+		"# title\n\nsome *emphasis*, **strong** and ~~deleted~~ text",
+		"- [ ] todo\n- [x] done\n  1. nested\n\n> quote #7",
+		"```go\nfunc main() {}\n```",
+		"[x](javascript:alert(1)) [y](java\x01script:1) <data:text/html,x>",
+		`[a"b](http://a.com/"onmouseover="alert(1)) https://a.com/?a="b`,
+		"<script>alert(1)</script> #7 #42 #0 `#7` [#7](https://a.com)",
+		"***a_b*c**_ \\*a\\* a  \nb",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, source string) {
+		output := Render(source, Options{TaskLink: taskLink})
+
+		if strings.Contains(output, referenceMark) {
+			t.Fatalf("Render(%q) = %q, contains a reference mark", source, output)
+		}
+		if strings.Contains(strings.ToLower(output), "<script") {
+			t.Fatalf("Render(%q) = %q, contains a script", source, output)
+		}
+		for _, m := range href.FindAllStringSubmatch(output, -1) {
+			url := strings.ToLower(m[1])
+			if strings.HasPrefix(url, "javascript:") || strings.HasPrefix(url, "data:") || strings.HasPrefix(url, "vbscript:") {
+				t.Fatalf("Render(%q) = %q, links to %q", source, output, m[1])
+			}
+		}
+		if !balanced(output) {
+			t.Fatalf("Render(%q) = %q, tags are not balanced", source, output)
+		}
+
+		document := Parse(source)
+		if linked := document.HTML(taskLink); linked != output {
+			t.Fatalf("Parse(%q).HTML() = %q, Render() = %q", source, linked, output)
+		}
+		for _, id := range document.References() {
+			if id == 0 {
+				t.Fatalf("References(%q) contains 0", source)
+			}
+		}
+	})
+}
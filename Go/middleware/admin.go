@@ -0,0 +1,17 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// AdminOnly rejects requests from users that are not administrators.
+func AdminOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !CurrentUser(c).IsAdmin {
+			return c.Status(403).JSON(fiber.Map{
+				"error":   true,
+				"message": "This endpoint is restricted to administrators",
+				"data":    nil,
+			})
+		}
+		return c.Next()
+	}
+}
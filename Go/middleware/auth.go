@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"encoding/json"
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	userKey         = "user"
+	loginKey        = "login"
+	tokenKey        = "token"
+	impersonatorKey = "impersonator"
+)
+
+// HeaderImpersonatedBy is set on every response to an impersonation session,
+// with the id of the admin acting as the user.
+const HeaderImpersonatedBy = "X-Impersonated-By"
+
+// the only write allowed to an impersonation session is ending it
+const logoutPath = "/api/logout"
+
+// Protected checks the bearer token, either a session access token issued by
+// /api/login or a personal access token, and puts the authenticated user on
+// c.Locals for the handlers after it. Without an Authorization header the
+// session cookie is used, and unsafe requests also need the CSRF token.
+func Protected() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		token, found := strings.CutPrefix(header, "Bearer ")
+
+		//browser sessions send the access token in the session cookie
+		fromCookie := false
+		if header == "" && config.App.SessionCookie != "" {
+			token = c.Cookies(config.App.SessionCookie)
+			found, fromCookie = true, true
+		}
+		if !found || token == "" {
+			return unauthorized(c, "Missing access token")
+		}
+
+		if strings.HasPrefix(token, auth.PersonalTokenPrefix) && !fromCookie {
+			return personalToken(c, token)
+		}
+
+		var login models.Login
+		result := database.DB.
+			Where("token_hash = ? AND expires_at > ? AND revoked_at IS NULL", auth.HashToken(token), time.Now()).
+			First(&login)
+		if result.RowsAffected == 0 {
+			return unauthorized(c, "Invalid or expired access token")
+		}
+		if fromCookie {
+			if message := checkCSRF(c, login); message != "" {
+				return c.Status(403).JSON(fiber.Map{
+					"error":   true,
+					"message": message,
+					"data":    nil,
+				})
+			}
+		}
+
+		var user models.User
+		if err := database.DB.First(&user, login.UserID).Error; err != nil {
+			return unauthorized(c, "User not found")
+		}
+		if user.DeactivatedAt != nil {
+			return unauthorized(c, "This account is deactivated")
+		}
+
+		c.Locals(userKey, user)
+		c.Locals(loginKey, login)
+		if login.ImpersonatorUserID != nil {
+			return impersonation(c, user, *login.ImpersonatorUserID)
+		}
+		return c.Next()
+	}
+}
+
+// runs a request of an admin acting as user. the session is read-only, and
+// every request is recorded with both the admin and the user.
+func impersonation(c *fiber.Ctx, user models.User, impersonatorID uint) error {
+	var impersonator models.User
+	if err := database.DB.First(&impersonator, impersonatorID).Error; err != nil ||
+		!impersonator.IsAdmin || impersonator.DeactivatedAt != nil {
+		return unauthorized(c, "Invalid or expired access token")
+	}
+	c.Locals(impersonatorKey, impersonator)
+	c.Set(HeaderImpersonatedBy, strconv.FormatUint(uint64(impersonator.ID), 10))
+
+	var err error
+	readOnly := c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead
+	if !readOnly && !(c.Method() == fiber.MethodPost && c.Path() == logoutPath) {
+		err = c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": "Impersonation sessions are read-only",
+			"data":    nil,
+		})
+	} else {
+		err = c.Next()
+	}
+
+	//recorded after the handler, so the status is known
+	status := c.Response().StatusCode()
+	if fiberErr, ok := err.(*fiber.Error); ok {
+		status = fiberErr.Code
+	} else if err != nil {
+		status = fiber.StatusInternalServerError
+	}
+	details, _ := json.Marshal(fiber.Map{
+		"method": c.Method(),
+		"path":   c.OriginalURL(),
+		"status": status,
+	})
+	database.DB.Create(&models.AuditLog{
+		ActorUserID:     &impersonator.ID,
+		EffectiveUserID: &user.ID,
+		Action:          models.AuditImpersonatedCall,
+		TargetType:      models.AuditTargetUser,
+		TargetID:        user.ID,
+		Details:         string(details),
+		ClientIP:        c.IP(),
+	})
+	return err
+}
+
+func personalToken(c *fiber.Ctx, token string) error {
+	var personalToken models.PersonalAccessToken
+	result := database.DB.
+		Preload("Boards").
+		Where("token_hash = ? AND expires_at > ? AND revoked_at IS NULL", auth.HashToken(token), time.Now()).
+		First(&personalToken)
+	if result.RowsAffected == 0 {
+		return unauthorized(c, "Invalid or expired access token")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, personalToken.UserID).Error; err != nil {
+		return unauthorized(c, "User not found")
+	}
+	if user.DeactivatedAt != nil {
+		return unauthorized(c, "This account is deactivated")
+	}
+
+	//read-only tokens can only read
+	if personalToken.ReadOnly && c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": "This access token is read-only",
+			"data":    nil,
+		})
+	}
+
+	database.DB.Model(&personalToken).Update("last_used_at", time.Now())
+
+	c.Locals(userKey, user)
+	c.Locals(tokenKey, personalToken)
+	return c.Next()
+}
+
+// SessionOnly rejects requests authenticated with a personal access token,
+// for endpoints that manage sessions and credentials.
+func SessionOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if CurrentLogin(c).ID == 0 {
+			return c.Status(403).JSON(fiber.Map{
+				"error":   true,
+				"message": "This endpoint requires a login session",
+				"data":    nil,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// CurrentUser returns the user set by Protected.
+func CurrentUser(c *fiber.Ctx) models.User {
+	user, _ := c.Locals(userKey).(models.User)
+	return user
+}
+
+// CurrentImpersonator returns the admin acting as the current user, or nil
+// when the request is not made under impersonation.
+func CurrentImpersonator(c *fiber.Ctx) *models.User {
+	impersonator, ok := c.Locals(impersonatorKey).(models.User)
+	if !ok {
+		return nil
+	}
+	return &impersonator
+}
+
+// CurrentLogin returns the session the access token belongs to.
+func CurrentLogin(c *fiber.Ctx) models.Login {
+	login, _ := c.Locals(loginKey).(models.Login)
+	return login
+}
+
+// CurrentToken returns the personal access token of the request, or nil when
+// the request was made with a session access token.
+func CurrentToken(c *fiber.Ctx) *models.PersonalAccessToken {
+	token, ok := c.Locals(tokenKey).(models.PersonalAccessToken)
+	if !ok {
+		return nil
+	}
+	return &token
+}
+
+func unauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(401).JSON(fiber.Map{
+		"error":   true,
+		"message": message,
+		"data":    nil,
+	})
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/models"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/helmet"
+)
+
+// HeaderCSRFToken carries the csrf_token of the login on unsafe requests
+// authenticated by the session cookie.
+const HeaderCSRFToken = "X-CSRF-Token"
+
+// CORS answers preflight requests and allows the configured origins to call
+// the API. Requests from other origins are left to the browser to block.
+func CORS() fiber.Handler {
+	if len(config.App.CORSAllowOrigins) == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	return cors.New(cors.Config{
+		AllowOrigins:     strings.Join(config.App.CORSAllowOrigins, ","),
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,HEAD",
+		AllowHeaders:     strings.Join([]string{fiber.HeaderAuthorization, fiber.HeaderContentType, HeaderCSRFToken}, ","),
+		AllowCredentials: config.App.CORSAllowCredentials,
+		ExposeHeaders:    HeaderImpersonatedBy,
+		MaxAge:           int(config.App.CORSMaxAge.Seconds()),
+	})
+}
+
+// SecurityHeaders sets the standard security headers on every response.
+func SecurityHeaders() fiber.Handler {
+	return helmet.New(helmet.Config{
+		XSSProtection:             "0",
+		ContentTypeNosniff:        "nosniff",
+		XFrameOptions:             config.App.FrameOptions,
+		HSTSMaxAge:                int(config.App.HSTSMaxAge.Seconds()),
+		ContentSecurityPolicy:     config.App.ContentSecurityPolicy,
+		ReferrerPolicy:            "no-referrer",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-site",
+		XPermittedCrossDomain:     "none",
+	})
+}
+
+// SessionCookie returns the cookie that carries the access token of a browser
+// session, or nil when cookie sessions are disabled. An empty token clears
+// the cookie.
+func SessionCookie(login models.Login, accessToken string) *fiber.Cookie {
+	if config.App.SessionCookie == "" {
+		return nil
+	}
+	return &fiber.Cookie{
+		Name:     config.App.SessionCookie,
+		Value:    accessToken,
+		Path:     "/api",
+		Expires:  login.ExpiresAt,
+		Secure:   config.App.CookieSecure,
+		HTTPOnly: true,
+		SameSite: config.App.CookieSameSite,
+	}
+}
+
+// a request authenticated by the session cookie is sent by the browser on
+// its own, so unsafe methods must prove they come from the frontend. returns
+// why the request was rejected, or an empty string.
+func checkCSRF(c *fiber.Ctx, login models.Login) string {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return ""
+	}
+
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin != "" && origin != c.BaseURL() && !slices.Contains(config.App.CORSAllowOrigins, origin) {
+		return "Origin is not allowed"
+	}
+
+	token := c.Get(HeaderCSRFToken)
+	expected := auth.CSRFToken(config.App.SecretKey, login.TokenHash)
+	if token == "" || !hmac.Equal([]byte(token), []byte(expected)) {
+		return "Missing or invalid CSRF token"
+	}
+	return ""
+}
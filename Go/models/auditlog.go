@@ -0,0 +1,39 @@
+package models
+
+import "gorm.io/gorm"
+
+// actions of an AuditLog
+const (
+	AuditGrantAdmin         = "user.grant_admin"
+	AuditRevokeAdmin        = "user.revoke_admin"
+	AuditDeactivateUser     = "user.deactivate"
+	AuditReactivateUser     = "user.reactivate"
+	AuditResetCredentials   = "user.reset_credentials"
+	AuditUpdateUser         = "user.update"
+	AuditChangeBoardOwner   = "board.change_owner"
+	AuditUnlockLoginLockout = "lockout.unlock"
+	AuditImpersonateUser    = "user.impersonate"
+	AuditImpersonatedCall   = "impersonation.request"
+)
+
+// targets of an AuditLog
+const (
+	AuditTargetUser    = "user"
+	AuditTargetBoard   = "board"
+	AuditTargetLockout = "lockout"
+)
+
+// AuditLog records an action taken by an administrator. ActorUserID is nil
+// for actions taken by the system, like the admin bootstrap. EffectiveUserID
+// is the user the admin was impersonating, if any. Details holds the
+// parameters of the action as JSON.
+type AuditLog struct {
+	gorm.Model
+	ActorUserID     *uint  `gorm:"index" json:"actor_user_id"`
+	EffectiveUserID *uint  `gorm:"index" json:"effective_user_id"`
+	Action          string `gorm:"column:action;size:50;index" json:"action"`
+	TargetType      string `gorm:"column:target_type;size:30" json:"target_type"`
+	TargetID        uint   `gorm:"column:target_id" json:"target_id"`
+	Details         string `gorm:"column:details;type:text" json:"details"`
+	ClientIP        string `gorm:"column:client_ip;size:45" json:"client_ip"`
+}
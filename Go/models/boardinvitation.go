@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// statuses of a BoardInvitation
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// BoardInvitation proposes a role on a board to a user. The invitee is
+// either an account (InviteeUserID) or an email address, which is matched
+// against verified accounts, including accounts created after the invite.
+// The user becomes a BoardMember when they accept.
+type BoardInvitation struct {
+	gorm.Model
+	Board           Board      `gorm:"foreignKey:BoardID;references:ID"`
+	BoardID         uint       `gorm:"index" json:"board_id"`
+	InvitedByUserID uint       `json:"invited_by_user_id"`
+	InviteeUserID   *uint      `gorm:"index" json:"invitee_user_id"`
+	Email           string     `gorm:"column:email;size:255;index" json:"email"`
+	Role            string     `gorm:"column:role;size:20" json:"role"`
+	Status          string     `gorm:"column:status;size:20;index" json:"status"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	RespondedAt     *time.Time `json:"responded_at"`
+}
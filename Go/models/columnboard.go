@@ -2,9 +2,26 @@ package models
 
 import "gorm.io/gorm"
 
+// DefaultColumnColor is used for columns created without a color.
+const DefaultColumnColor = "#6b7280"
+
 type ColumnBoard struct {
 	gorm.Model
 	Board      Board  `gorm:"foreignKey:BoardID;references:ID"`
-	ColumnName string `gorm:"column:column_name;size:20;" json:"column_name"`
+	ColumnName string `gorm:"column:column_name;size:50;" json:"column_name"`
+	Color      string `gorm:"column:color;size:7;" json:"color"`
+	Position   int    `gorm:"column:position;not null;default:0" json:"position"`
+	IsDone     bool   `gorm:"column:is_done;not null;default:false" json:"is_done"`
 	BoardID    uint   `json:"board_id"`
 }
+
+// DefaultColumns returns the columns of a new board. boards created before
+// columns were configurable are migrated to the same set.
+func DefaultColumns(boardID uint) []ColumnBoard {
+	return []ColumnBoard{
+		{BoardID: boardID, ColumnName: "To Do", Color: DefaultColumnColor, Position: 0},
+		{BoardID: boardID, ColumnName: "Doing", Color: "#3b82f6", Position: 1},
+		{BoardID: boardID, ColumnName: "Done", Color: "#22c55e", Position: 2, IsDone: true},
+		{BoardID: boardID, ColumnName: "Accepted", Color: "#a855f7", Position: 3, IsDone: true},
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gofiber/keyring"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// EncryptedSerializer stores string fields encrypted with keyring.Default.
+// Empty strings are stored as NULL. Values written before the column was
+// encrypted are read as they are.
+type EncryptedSerializer struct{}
+
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch value := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = string(value)
+	case string:
+		stored = value
+	default:
+		return fmt.Errorf("encrypted: unsupported value %T for %s", dbValue, field.Name)
+	}
+
+	plaintext := stored
+	if keyring.IsEncrypted(stored) {
+		if keyring.Default == nil {
+			return errors.New("encrypted: key ring is not loaded")
+		}
+		var err error
+		if plaintext, err = keyring.Default.Decrypt(stored); err != nil {
+			return err
+		}
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, _ := fieldValue.(string)
+	if plaintext == "" {
+		return nil, nil
+	}
+	if keyring.Default == nil {
+		return nil, errors.New("encrypted: key ring is not loaded")
+	}
+	return keyring.Default.Encrypt(plaintext)
+}
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// ExternalIdentity links a user to an account at an OpenID provider.
+type ExternalIdentity struct {
+	gorm.Model
+	User    User   `gorm:"foreignKey:UserID;references:ID"`
+	UserID  uint   `json:"user_id"`
+	Issuer  string `gorm:"column:issuer;size:255;uniqueIndex:idx_issuer_subject" json:"issuer"`
+	Subject string `gorm:"column:subject;size:255;uniqueIndex:idx_issuer_subject" json:"subject"`
+}
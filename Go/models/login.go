@@ -1,9 +1,26 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Login is a session created by /api/login. The access token is short lived
+// and can be renewed with the refresh token until the session expires or is
+// revoked. Sessions with an ImpersonatorUserID were issued to an admin acting
+// as the user, they are read-only and can not be refreshed.
 type Login struct {
 	gorm.Model
-	User   User `gorm:"foreignKey:UserID;references:ID"`
-	UserID uint `json:"user_id"`
+	User             User       `gorm:"foreignKey:UserID;references:ID"`
+	UserID           uint       `json:"user_id"`
+	TokenHash        string     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshTokenHash string     `gorm:"column:refresh_token_hash;size:64;uniqueIndex" json:"-"`
+	RefreshExpiresAt time.Time  `json:"refresh_expires_at"`
+	ClientIP         string     `gorm:"column:client_ip;size:45" json:"client_ip"`
+	UserAgent        string     `gorm:"column:user_agent;size:255" json:"user_agent"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+
+	ImpersonatorUserID *uint `json:"impersonator_user_id"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// results of a LoginAttempt
+const (
+	LoginSucceeded  = "success"
+	LoginFailed     = "failed"
+	LoginBlocked    = "blocked"
+	LoginLocked     = "locked"
+	LoginChallenged = "challenged"
+)
+
+// LoginAttempt is the login history, one row for every call to /api/login
+// plus one row for every lockout it triggered. UserID is nil when the
+// username does not belong to any account. A password that was correct but
+// still needs the second factor is LoginChallenged.
+type LoginAttempt struct {
+	gorm.Model
+	UserID    *uint  `json:"user_id"`
+	Username  string `gorm:"column:username;size:50;index" json:"username"`
+	ClientIP  string `gorm:"column:client_ip;size:45;index" json:"client_ip"`
+	UserAgent string `gorm:"column:user_agent;size:255" json:"user_agent"`
+	Result    string `gorm:"column:result;size:20" json:"result"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginChallenge is issued by /api/login when the password was correct but
+// the user has two-factor authentication. It has to be completed with a TOTP
+// or recovery code to get a session.
+type LoginChallenge struct {
+	gorm.Model
+	User      User       `gorm:"foreignKey:UserID;references:ID"`
+	UserID    uint       `json:"user_id"`
+	TokenHash string     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	Attempts  int        `json:"attempts"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// scopes of a LoginLockout
+const (
+	LockoutAccount = "account"
+	LockoutIP      = "ip"
+)
+
+// LoginLockout blocks logins for a username or a client IP until LockedUntil,
+// or until an admin unlocks it.
+type LoginLockout struct {
+	gorm.Model
+	Scope            string     `gorm:"column:scope;size:10;index:idx_lockout_subject" json:"scope"`
+	Subject          string     `gorm:"column:subject;size:50;index:idx_lockout_subject" json:"subject"`
+	LockedUntil      time.Time  `json:"locked_until"`
+	UnlockedAt       *time.Time `json:"unlocked_at"`
+	UnlockedByUserID *uint      `json:"unlocked_by_user_id"`
+}
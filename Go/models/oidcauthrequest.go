@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCAuthRequest keeps the state, nonce and PKCE verifier of a single-sign-on
+// login between the redirect to the provider and the callback.
+type OIDCAuthRequest struct {
+	gorm.Model
+	StateHash    string     `gorm:"column:state_hash;size:64;uniqueIndex" json:"-"`
+	Nonce        string     `gorm:"column:nonce;size:64" json:"-"`
+	CodeVerifier string     `gorm:"column:code_verifier;size:64" json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+}
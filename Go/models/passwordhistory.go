@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// PasswordHistory keeps the hashes of the passwords a user has set, so the
+// password policy can refuse to reuse them.
+type PasswordHistory struct {
+	gorm.Model
+	User         User   `gorm:"foreignKey:UserID;references:ID"`
+	UserID       uint   `gorm:"index" json:"user_id"`
+	PasswordHash string `gorm:"column:password_hash;size:255" json:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordReset is a single-use token sent by email to reset a forgotten
+// password. Only the hash of the token is stored.
+type PasswordReset struct {
+	gorm.Model
+	User      User       `gorm:"foreignKey:UserID;references:ID"`
+	UserID    uint       `json:"user_id"`
+	TokenHash string     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PersonalAccessToken lets bots and CI call the API on behalf of a user
+// without a password login. Boards limits the token to some boards, an empty
+// list means every board the user can access.
+type PersonalAccessToken struct {
+	gorm.Model
+	User       User                       `gorm:"foreignKey:UserID;references:ID"`
+	Boards     []PersonalAccessTokenBoard `gorm:"foreignKey:PersonalAccessTokenID;references:ID"`
+	UserID     uint                       `json:"user_id"`
+	Name       string                     `gorm:"column:name;size:50" json:"name"`
+	TokenHash  string                     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"`
+	ReadOnly   bool                       `gorm:"column:read_only" json:"read_only"`
+	ExpiresAt  time.Time                  `json:"expires_at"`
+	LastUsedAt *time.Time                 `json:"last_used_at"`
+	RevokedAt  *time.Time                 `json:"revoked_at"`
+}
+
+type PersonalAccessTokenBoard struct {
+	gorm.Model
+	Board                 Board `gorm:"foreignKey:BoardID;references:ID"`
+	PersonalAccessTokenID uint  `json:"personal_access_token_id"`
+	BoardID               uint  `json:"board_id"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a hashed one-time code that replaces a TOTP code when the
+// authenticator is lost.
+type RecoveryCode struct {
+	gorm.Model
+	User     User       `gorm:"foreignKey:UserID;references:ID"`
+	UserID   uint       `json:"user_id"`
+	CodeHash string     `gorm:"column:code_hash;size:64;index" json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
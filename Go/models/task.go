@@ -10,8 +10,11 @@ type Task struct {
 	gorm.Model
 	ColumnBoard    ColumnBoard `gorm:"foreignKey:ColumnBoardID;references:ID"`
 	User           User        `gorm:"foreignKey:CreateByUserID;references:ID"`
-	Title          string      `gorm:"column:title;size:20;" json:"title"`
+	Title          string      `gorm:"column:title;size:255;" json:"title"`
+	Description    string      `gorm:"column:description;type:mediumtext" json:"description"`
 	DueDate        time.Time   `json:"due_date"`
-	ColumnBoardID  uint        `json:"column_board_id"`
+	ColumnBoardID  uint        `gorm:"index:idx_tasks_column_rank,priority:1" json:"column_board_id"`
 	CreateByUserID uint        `json:"create_by_user_id"`
+	//order of the task in its column, see package rank
+	Rank string `gorm:"column:rank_key;size:32;index:idx_tasks_column_rank,priority:2" json:"rank"`
 }
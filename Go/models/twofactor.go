@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TwoFactor is the TOTP second factor of a user. It only applies to logins
+// once ConfirmedAt is set. The secret is encrypted like the IDCard.
+type TwoFactor struct {
+	gorm.Model
+	User         User       `gorm:"foreignKey:UserID;references:ID"`
+	UserID       uint       `gorm:"uniqueIndex" json:"user_id"`
+	Secret       string     `gorm:"column:secret;size:255;serializer:encrypted" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `json:"-"`
+}
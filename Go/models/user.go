@@ -1,11 +1,37 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gofiber/keyring"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
-	IDCard   string `gorm:"column:id_card;size:13;unique" json:"id_card"`
+	IDCard   string `gorm:"column:id_card;size:255;serializer:encrypted" json:"-"`
 	Username string `gorm:"column:username;size:50;unique" json:"username"`
-	Password string `gorm:"column:password;size:10" json:"password"`
-	Email    string `gorm:"column:email;size:20" json:"email"`
+	Password string `gorm:"column:password;size:255" json:"-"`
+	Email    string `gorm:"column:email;size:255" json:"email"`
+	IsAdmin  bool   `gorm:"column:is_admin;default:false" json:"-"`
+
+	//blind index of IDCard for lookups and the unique check, NULL when the
+	//user has no IDCard
+	IDCardIndex *string `gorm:"column:id_card_index;size:64;uniqueIndex" json:"-"`
+
+	EmailVerifiedAt    *time.Time `json:"-"`
+	VerificationSentAt *time.Time `json:"-"`
+
+	//deactivated users can not log in, their data is kept
+	DeactivatedAt *time.Time `json:"-"`
+}
+
+// SetIDCard sets the IDCard and its blind index.
+func (u *User) SetIDCard(idCard string) {
+	u.IDCard = idCard
+	u.IDCardIndex = nil
+	if idCard != "" {
+		index := keyring.Default.BlindIndex(idCard)
+		u.IDCardIndex = &index
+	}
 }
@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clock skew tolerated on exp and iat
+const allowedSkew = time.Minute
+
+// keys are fetched again after this, or when a token uses an unknown kid
+const keysTTL = time.Hour
+
+// the JWKS is fetched at most this often, so tokens with made up kids can not
+// make every callback wait for the provider
+const keysRefetch = time.Minute
+
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+}
+
+// aud is either a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token and returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, clientID string, rawIDToken string, nonce string) (*Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed id token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed id token signature")
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != p.Issuer:
+		return nil, errors.New("oidc: id token issuer mismatch")
+	case !claims.Audience.contains(clientID):
+		return nil, errors.New("oidc: id token audience mismatch")
+	case now.After(time.Unix(claims.Expiry, 0).Add(allowedSkew)):
+		return nil, errors.New("oidc: id token expired")
+	case claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(allowedSkew)):
+		return nil, errors.New("oidc: id token issued in the future")
+	case claims.Nonce != nonce:
+		return nil, errors.New("oidc: id token nonce mismatch")
+	case claims.Subject == "":
+		return nil, errors.New("oidc: id token without subject")
+	}
+	return &claims, nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func verifySignature(alg string, key any, signed string, signature []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "RS256":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, sum[:], signature) != nil {
+			return errors.New("oidc: invalid id token signature")
+		}
+	case "ES256":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return errors.New("oidc: invalid id token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, sum[:], r, s) {
+			return errors.New("oidc: invalid id token signature")
+		}
+	default:
+		return fmt.Errorf("oidc: unsupported id token algorithm %q", alg)
+	}
+	return nil
+}
+
+// returns the signing key with kid, fetching the JWKS when the cache is
+// stale or does not know the kid. the lock is not held during the fetch
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	if ok && time.Since(p.keysFetch) < keysTTL {
+		p.mu.Unlock()
+		return key, nil
+	}
+	//fetched recently, successful or not: unknown kids fail fast and stale
+	//keys are still used
+	if time.Since(p.keysAttempt) < keysRefetch {
+		p.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	p.keysAttempt = time.Now()
+	p.mu.Unlock()
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetch = time.Now()
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// returns the signing keys of the JWKS by kid
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]any, error) {
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, p.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := map[string]any{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if jwk.Crv != "P-256" || errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("oidc: malformed id token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("oidc: malformed id token")
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"gofiber/oidc"
+	"gofiber/oidc/oidctest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const clientID = "kanban"
+
+var config = oidc.Config{
+	ClientID:    clientID,
+	RedirectURL: "http://localhost:8000/api/oidc/callback",
+	Scopes:      []string{"openid", "email"},
+}
+
+func discover(t *testing.T, mock *oidctest.Provider) *oidc.Provider {
+	t.Helper()
+	provider, err := oidc.Discover(context.Background(), mock.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestDiscoverChecksIssuer(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+
+	provider := discover(t, mock)
+	if provider.TokenEndpoint != mock.URL+"/token" {
+		t.Errorf("token endpoint = %q", provider.TokenEndpoint)
+	}
+	if _, err := oidc.Discover(context.Background(), mock.URL+"/", nil); err == nil {
+		t.Error("Discover accepted a document for another issuer")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	provider := discover(t, oidctest.NewProvider(t, clientID))
+
+	authURL, err := url.Parse(provider.AuthCodeURL(config, "the-state", "the-nonce", "the-challenge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             clientID,
+		"redirect_uri":          config.RedirectURL,
+		"scope":                 "openid email",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        "the-challenge",
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExchangeChecksPKCE(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	provider := discover(t, mock)
+
+	verifier, challenge, err := oidc.GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherVerifier, _, err := oidc.GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier string
+		wantErr  bool
+	}{
+		{"matching verifier", verifier, false},
+		{"other verifier", otherVerifier, true},
+		{"no verifier", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, err := mock.Authorize(provider.AuthCodeURL(config, "state", "nonce", challenge), map[string]any{"sub": "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = provider.Exchange(context.Background(), config, code, tt.verifier)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	provider := discover(t, mock)
+
+	verifier, challenge, err := oidc.GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := mock.Authorize(provider.AuthCodeURL(config, "state", "nonce", challenge), map[string]any{"sub": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), config, code, verifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), config, code, verifier); err == nil {
+		t.Error("the code was exchanged twice")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	provider := discover(t, mock)
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":            mock.URL,
+			"sub":            "alice",
+			"aud":            clientID,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          "the-nonce",
+			"email":          "alice@example.com",
+			"email_verified": true,
+		}
+	}
+	with := func(name string, value any) string {
+		claims := valid()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return mock.SignIDToken(claims)
+	}
+	tampered := func() string {
+		parts := strings.Split(mock.SignIDToken(valid()), ".")
+		other := strings.Split(with("sub", "mallory"), ".")
+		return parts[0] + "." + other[1] + "." + parts[2]
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"valid", mock.SignIDToken(valid()), ""},
+		{"audience list", with("aud", []string{"other", clientID}), ""},
+		{"other nonce", with("nonce", "replayed"), "nonce mismatch"},
+		{"no nonce", with("nonce", nil), "nonce mismatch"},
+		{"other audience", with("aud", "other"), "audience mismatch"},
+		{"other issuer", with("iss", "https://evil.example.com"), "issuer mismatch"},
+		{"expired", with("exp", time.Now().Add(-time.Hour).Unix()), "expired"},
+		{"issued in the future", with("iat", time.Now().Add(time.Hour).Unix()), "in the future"},
+		{"no subject", with("sub", nil), "without subject"},
+		{"tampered payload", tampered(), "invalid id token signature"},
+		{"malformed", "not-a-token", "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.VerifyIDToken(context.Background(), clientID, tt.token, "the-nonce")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyIDToken() error = %v", err)
+				}
+				if claims.Subject != "alice" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+					t.Errorf("VerifyIDToken() claims = %+v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyIDToken() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyIDsDoNotRefetchKeys(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	provider := discover(t, mock)
+
+	claims := map[string]any{
+		"iss":   mock.URL,
+		"sub":   "alice",
+		"aud":   clientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": "the-nonce",
+	}
+	if _, err := provider.VerifyIDToken(context.Background(), clientID, mock.SignIDToken(claims), "the-nonce"); err != nil {
+		t.Fatalf("VerifyIDToken() error = %v", err)
+	}
+
+	parts := strings.Split(mock.SignIDToken(claims), ".")
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"made-up","typ":"JWT"}`))
+	forged := header + "." + parts[1] + "." + parts[2]
+	for range 5 {
+		_, err := provider.VerifyIDToken(context.Background(), clientID, forged, "the-nonce")
+		if err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+			t.Fatalf("VerifyIDToken() error = %v, want unknown signing key", err)
+		}
+	}
+	if got := mock.JWKSRequests(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
@@ -0,0 +1,195 @@
+// Package oidctest runs an OpenID provider in memory for tests. It serves
+// the discovery document, the JWKS and a token endpoint that checks the PKCE
+// code verifier, and signs ID tokens with a fresh RSA key.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const keyID = "test-key"
+
+type Provider struct {
+	*httptest.Server
+	ClientID string
+
+	key *rsa.PrivateKey
+
+	mu           sync.Mutex
+	codes        map[string]authorization
+	jwksRequests int
+}
+
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	claims      map[string]any
+}
+
+// NewProvider starts a provider for clientID, it is closed with the test.
+func NewProvider(t testing.TB, clientID string) *Provider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{
+		ClientID: clientID,
+		key:      key,
+		codes:    map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Authorize logs in at the provider with an URL built by AuthCodeURL and
+// returns the code and state the browser is redirected back with. claims
+// are added to the ID token, they override the defaults (iss, aud, exp, iat
+// and the nonce of the URL).
+func (p *Provider) Authorize(authURL string, claims map[string]any) (code string, state string, err error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := parsed.Query()
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		return "", "", errors.New("oidctest: unsupported authorization request")
+	}
+
+	idClaims := map[string]any{
+		"iss":   p.URL,
+		"aud":   query.Get("client_id"),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code = randomString()
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		clientID:    query.Get("client_id"),
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		claims:      idClaims,
+	}
+	p.mu.Unlock()
+	return code, query.Get("state"), nil
+}
+
+// SignIDToken returns claims signed with the key of the provider.
+func (p *Provider) SignIDToken(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// JWKSRequests returns how often the JWKS was fetched.
+func (p *Provider) JWKSRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jwksRequests
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.jwksRequests++
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// codes can be used once, and only with the verifier of their challenge
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok:
+		tokenError(w, "invalid_grant")
+	case auth.clientID != p.ClientID || r.PostForm.Get("client_id") != p.ClientID:
+		tokenError(w, "invalid_client")
+	case auth.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, "invalid_grant")
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge:
+		tokenError(w, "invalid_grant")
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{
+			"access_token": randomString(),
+			"token_type":   "Bearer",
+			"id_token":     p.SignIDToken(auth.claims),
+		})
+	}
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
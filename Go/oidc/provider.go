@@ -0,0 +1,163 @@
+// Package oidc is a small OpenID Connect relying party for the
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider is an OpenID provider loaded from its discovery document.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]any
+	keysFetch   time.Time
+	keysAttempt time.Time
+}
+
+// Discover loads the provider configuration from
+// {issuer}/.well-known/openid-configuration. client may be nil.
+func Discover(ctx context.Context, issuer string, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	provider := &Provider{client: client}
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, client, wellKnown, provider); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if provider.Issuer != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", provider.Issuer, issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+	return provider, nil
+}
+
+// AuthCodeURL returns the URL the browser is sent to for login.
+func (p *Provider) AuthCodeURL(config Config, state string, nonce string, codeChallenge string) string {
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", config.ClientID)
+	values.Set("redirect_uri", config.RedirectURL)
+	values.Set("scope", strings.Join(config.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// Exchange trades the authorization code for tokens and returns the raw ID
+// token.
+func (p *Provider) Exchange(ctx context.Context, config Config, code string, codeVerifier string) (string, error) {
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", config.RedirectURL)
+	values.Set("client_id", config.ClientID)
+	values.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return "", fmt.Errorf("oidc token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("oidc token: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("oidc token: no id_token in response")
+	}
+	return token.IDToken, nil
+}
+
+// GeneratePKCE returns a code verifier and its S256 code challenge.
+func GeneratePKCE() (verifier string, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns 32 random bytes, url-safe encoded, for states,
+// nonces and code verifiers.
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,68 @@
+// Package permissions holds the board role permission matrix.
+//
+//	permission       viewer  preparer  reviewer  admin
+//	board:view         x        x         x        x
+//	member:view        x        x         x        x
+//	column:view        x        x         x        x
+//	column:manage                         x        x
+//	task:view          x        x         x        x
+//	task:create                 x         x        x
+//	task:update                 x         x        x
+//	task:delete                           x        x
+//	assignee:view      x        x         x        x
+//	assignee:manage             x         x        x
+//	member:manage                                  x
+//	board:manage                                   x
+//
+// The board owner is implicitly an admin of the board. Transferring the
+// ownership is reserved to the owner.
+package permissions
+
+type Permission string
+
+const (
+	ViewBoard      Permission = "board:view"
+	ManageBoard    Permission = "board:manage"
+	ViewMember     Permission = "member:view"
+	ManageMember   Permission = "member:manage"
+	ViewColumn     Permission = "column:view"
+	ManageColumn   Permission = "column:manage"
+	ViewTask       Permission = "task:view"
+	CreateTask     Permission = "task:create"
+	UpdateTask     Permission = "task:update"
+	DeleteTask     Permission = "task:delete"
+	ViewAssignee   Permission = "assignee:view"
+	ManageAssignee Permission = "assignee:manage"
+)
+
+const (
+	RoleViewer   = "viewer"
+	RolePreparer = "preparer"
+	RoleReviewer = "reviewer"
+	RoleAdmin    = "admin"
+)
+
+var viewPermissions = []Permission{ViewBoard, ViewMember, ViewColumn, ViewTask, ViewAssignee}
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer:   viewPermissions,
+	RolePreparer: append([]Permission{CreateTask, UpdateTask, ManageAssignee}, viewPermissions...),
+	RoleReviewer: append([]Permission{CreateTask, UpdateTask, DeleteTask, ManageAssignee, ManageColumn}, viewPermissions...),
+	RoleAdmin:    append([]Permission{CreateTask, UpdateTask, DeleteTask, ManageAssignee, ManageColumn, ManageMember, ManageBoard}, viewPermissions...),
+}
+
+// ValidRole reports whether role is one of the board member roles.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows reports whether a board member with role has permission.
+func RoleAllows(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+// Package rank orders items with lexicographic keys. A key is a fraction
+// between 0 and 1 written in base 36 without the leading "0.", so an item
+// can be moved between two others by giving it a key between theirs, and
+// no other item has to change.
+//
+// Keys never end with "0", which keeps room between any two of them. They
+// grow by about one character every few inserts at the same place, so once a
+// key gets longer than MaxLength the items should be given fresh keys with
+// Spread.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// MaxLength is the length after which the keys of a list should be spread
+// again.
+const MaxLength = 24
+
+var (
+	ErrInvalidKey = errors.New("rank: invalid key")
+	ErrOrder      = errors.New("rank: keys are not in order")
+)
+
+// Between returns a key that sorts after prev and before next. An empty prev
+// is the start of the list and an empty next is the end.
+func Between(prev string, next string) (string, error) {
+	if !valid(prev) || !valid(next) {
+		return "", ErrInvalidKey
+	}
+	if next != "" && prev >= next {
+		return "", ErrOrder
+	}
+	return midpoint(prev, next, next != ""), nil
+}
+
+// Spread returns n keys in order, evenly spaced over the whole range so
+// there is room for inserts everywhere.
+func Spread(n int) []string {
+	//one character more than needed to tell them apart leaves room between
+	width := 1
+	for size := base; size < (n+1)*base; size *= base {
+		width++
+	}
+	size := 1
+	for i := 0; i < width; i++ {
+		size *= base
+	}
+
+	keys := make([]string, 0, n)
+	step := size / (n + 1)
+	for i := 1; i <= n; i++ {
+		keys = append(keys, format(i*step, width))
+	}
+	return keys
+}
+
+// midpoint of the fractions a and b, b is 1 when bounded is false
+func midpoint(a string, b string, bounded bool) string {
+	if bounded {
+		//keep the common prefix, a is padded with zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(trim(a, n), b[n:], true)
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(digits, a[0])
+	}
+	digitB := base
+	if bounded {
+		digitB = strings.IndexByte(digits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2])
+	}
+
+	//the first digits are neighbours, b can be cut short if it is longer
+	if bounded && len(b) > 1 {
+		return b[:1]
+	}
+	return string(digits[digitA]) + midpoint(trim(a, 1), "", false)
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return '0'
+}
+
+func trim(key string, n int) string {
+	if n >= len(key) {
+		return ""
+	}
+	return key[n:]
+}
+
+func format(value int, width int) string {
+	key := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		key[i] = digits[value%base]
+		value /= base
+	}
+	return strings.TrimRight(string(key), "0")
+}
+
+func valid(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(key, "0")
+}
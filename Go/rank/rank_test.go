@@ -0,0 +1,154 @@
+package rank
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		prev string
+		next string
+	}{
+		{"", ""},
+		{"", "i"},
+		{"i", ""},
+		{"a", "b"},
+		{"a", "a1"},
+		{"a1", "a2"},
+		{"az", "b"},
+		{"y", "z"},
+		{"z", ""},
+		{"zzzz", ""},
+		{"", "1"},
+		{"", "01"},
+		{"001", "002"},
+		{"abc", "abd"},
+		{"abcz", "abd"},
+	}
+	for _, tt := range tests {
+		key, err := Between(tt.prev, tt.next)
+		if err != nil {
+			t.Errorf("Between(%q, %q) error = %v", tt.prev, tt.next, err)
+			continue
+		}
+		if !valid(key) || key == "" {
+			t.Errorf("Between(%q, %q) = %q, not a valid key", tt.prev, tt.next, key)
+		}
+		if key <= tt.prev || (tt.next != "" && key >= tt.next) {
+			t.Errorf("Between(%q, %q) = %q, not in between", tt.prev, tt.next, key)
+		}
+	}
+}
+
+func TestBetweenErrors(t *testing.T) {
+	tests := []struct {
+		prev string
+		next string
+		want error
+	}{
+		{"b", "a", ErrOrder},
+		{"a", "a", ErrOrder},
+		{"a0", "b", ErrInvalidKey},
+		{"A", "b", ErrInvalidKey},
+		{"a", "b-", ErrInvalidKey},
+		{"a", "0", ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		if _, err := Between(tt.prev, tt.next); err != tt.want {
+			t.Errorf("Between(%q, %q) error = %v, want %v", tt.prev, tt.next, err, tt.want)
+		}
+	}
+}
+
+// inserting at random places always keeps the keys valid, distinct and in
+// the order of insertion
+func TestBetweenKeepsOrder(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		index := random.Intn(len(keys) + 1)
+		prev, next := "", ""
+		if index > 0 {
+			prev = keys[index-1]
+		}
+		if index < len(keys) {
+			next = keys[index]
+		}
+		key, err := Between(prev, next)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) error = %v", prev, next, err)
+		}
+		keys = slices.Insert(keys, index, key)
+	}
+	if !slices.IsSorted(keys) {
+		t.Error("keys are not sorted")
+	}
+	if len(slices.Compact(slices.Clone(keys))) != len(keys) {
+		t.Error("keys are not distinct")
+	}
+}
+
+// inserting at the same place makes the keys grow slowly, so MaxLength is
+// only reached after many moves
+func TestBetweenGrowth(t *testing.T) {
+	prev, next := "", ""
+	for i := 0; i < 40; i++ {
+		key, err := Between(prev, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key) > MaxLength {
+			t.Fatalf("key %q longer than MaxLength after %d inserts", key, i)
+		}
+		next = key
+	}
+
+	key, err := Between(strings.Repeat("z", MaxLength), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) <= MaxLength {
+		t.Errorf("Between() after the last key of MaxLength = %q, want a longer key", key)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 100, 1295, 1296, 5000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		for i, key := range keys {
+			if !valid(key) || key == "" {
+				t.Fatalf("Spread(%d)[%d] = %q, not a valid key", n, i, key)
+			}
+			if i > 0 && keys[i-1] >= key {
+				t.Fatalf("Spread(%d) is not strictly increasing at %d: %q >= %q", n, i, keys[i-1], key)
+			}
+			if len(key) >= MaxLength {
+				t.Fatalf("Spread(%d)[%d] = %q is too long", n, i, key)
+			}
+		}
+
+		//there is room before the first, between neighbours and after the last
+		longest := 0
+		for _, key := range keys {
+			longest = max(longest, len(key))
+		}
+		for i := 0; i <= len(keys); i++ {
+			prev, next := "", ""
+			if i > 0 {
+				prev = keys[i-1]
+			}
+			if i < len(keys) {
+				next = keys[i]
+			}
+			if key, err := Between(prev, next); err != nil || len(key) > longest+1 {
+				t.Fatalf("Spread(%d): Between(%q, %q) = %q, %v", n, prev, next, key, err)
+			}
+		}
+	}
+}
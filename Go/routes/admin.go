@@ -0,0 +1,493 @@
+package routes
+
+import (
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"gofiber/validation"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// AdminUser is the user as administrators see it, with the account status
+type AdminUser struct {
+	User
+	IsAdmin       bool       `json:"is_admin"`
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func createResponseAdminUser(user models.User, viewer models.User) AdminUser {
+	return AdminUser{
+		User:          CreateResponseUser(user, viewer),
+		IsAdmin:       user.IsAdmin,
+		DeactivatedAt: user.DeactivatedAt,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+type AdminBoard struct {
+	ID        uint      `json:"id"`
+	BoardName string    `json:"board_name"`
+	OwnerID   uint      `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func createResponseAdminBoard(board models.Board) AdminBoard {
+	return AdminBoard{
+		ID:        board.ID,
+		BoardName: board.BoardName,
+		OwnerID:   board.OwnerID,
+		CreatedAt: board.CreatedAt,
+	}
+}
+
+// GET All User, searched by q (username, email or ID card) and filtered by
+// status (active or deactivated) and admin
+func GetAdminUsers(c *fiber.Ctx) error {
+	viewer := middleware.CurrentUser(c)
+	users := []models.User{}
+
+	query := database.DB.Order("id")
+	if q := c.Query("q"); q != "" {
+		like := "%" + q + "%"
+		idCard := validation.NormalizeIDCard(q)
+		if validation.ValidateIDCard(idCard) == nil {
+			//ID cards are encrypted, they are searched by blind index
+			user := models.User{}
+			user.SetIDCard(idCard)
+			query = query.Where("username LIKE ? OR email LIKE ? OR id_card_index = ?", like, like, *user.IDCardIndex)
+		} else {
+			query = query.Where("username LIKE ? OR email LIKE ?", like, like)
+		}
+	}
+	switch c.Query("status") {
+	case "active":
+		query = query.Where("deactivated_at IS NULL")
+	case "deactivated":
+		query = query.Where("deactivated_at IS NOT NULL")
+	}
+	if admin := c.Query("admin"); admin != "" {
+		query = query.Where("is_admin = ?", c.QueryBool("admin"))
+	}
+
+	//read database
+	if err := query.Find(&users).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	responseUsers := []AdminUser{}
+
+	for _, user := range users {
+		responseUser := createResponseAdminUser(user, viewer)
+		responseUsers = append(responseUsers, responseUser)
+	}
+	return c.Status(200).JSON(responseUsers)
+}
+
+// PUT grant or revoke the administrator role
+func SetUserAdmin(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	admin := middleware.CurrentUser(c)
+	var user models.User
+
+	//check user id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find User
+	if err := findUser(id, &user); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	type SetUserAdmin struct {
+		IsAdmin *bool `json:"is_admin"`
+	}
+
+	var adminInput SetUserAdmin
+
+	//parsing validation
+	if err := c.BodyParser(&adminInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	if adminInput.IsAdmin == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "is_admin is required",
+			"data":    nil,
+		})
+	}
+
+	//an admin can not demote themselves, so there is always one left
+	if user.ID == admin.ID && !*adminInput.IsAdmin {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "You can not revoke your own administrator role",
+			"data":    nil,
+		})
+	}
+
+	action := models.AuditRevokeAdmin
+	if *adminInput.IsAdmin {
+		action = models.AuditGrantAdmin
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if !*adminInput.IsAdmin {
+			if last, err := isLastAdmin(tx, user.ID); err != nil {
+				return err
+			} else if last {
+				return errLastAdminRevoked
+			}
+		}
+		if err := tx.Model(&user).Update("is_admin", *adminInput.IsAdmin).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, action, models.AuditTargetUser, user.ID, nil)
+	})
+	if err == errLastAdminRevoked {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseUser := createResponseAdminUser(user, admin)
+	return c.Status(200).JSON(responseUser)
+}
+
+// POST deactivate an account, its boards and assignments go to a successor
+func DeactivateUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	admin := middleware.CurrentUser(c)
+	var user models.User
+
+	//check user id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find User
+	if err := findUser(id, &user); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	if user.ID == admin.ID {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "You can not deactivate your own account",
+			"data":    nil,
+		})
+	}
+	return deactivateUser(c, user)
+}
+
+// POST reactivate a deactivated account
+func ReactivateUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	admin := middleware.CurrentUser(c)
+	var user models.User
+
+	//check user id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find User
+	if err := findUser(id, &user); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	if user.DeactivatedAt == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Account is not deactivated",
+			"data":    nil,
+		})
+	}
+
+	user.DeactivatedAt = nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("deactivated_at", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditReactivateUser, models.AuditTargetUser, user.ID, nil)
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseUser := createResponseAdminUser(user, admin)
+	return c.Status(200).JSON(responseUser)
+}
+
+// POST reset credentials. the password is replaced by a random one, every
+// session, access token and second factor is removed, and the user gets a
+// password reset email to choose a new password.
+func ResetUserCredentials(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	var user models.User
+
+	//check user id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find User
+	if err := findUser(id, &user); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	//nobody knows the new password
+	randomPassword, _, err := auth.GenerateToken()
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	hashedPassword, err := auth.HashPassword(randomPassword)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		if err := revokeUserAccess(tx, user.ID, 0); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditResetCredentials, models.AuditTargetUser, user.ID, nil)
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	//the reset is done even if the email can not be sent, the user can ask
+	//for a new link
+	emailSent := sendPasswordReset(user) == nil
+
+	return c.Status(200).JSON(fiber.Map{
+		"error":   false,
+		"message": "Successfully Reset Credentials",
+		"data": fiber.Map{
+			"reset_email_sent": emailSent,
+		},
+	})
+}
+
+// POST issue a short-lived, read-only session to act as the user
+func ImpersonateUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	admin := middleware.CurrentUser(c)
+	var user models.User
+
+	//check user id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find User
+	if err := findUser(id, &user); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	type ImpersonateUser struct {
+		Reason string `json:"reason"`
+	}
+
+	var impersonateInput ImpersonateUser
+
+	//parsing validation
+	if err := c.BodyParser(&impersonateInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//input validation
+	if impersonateInput.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Reason is required",
+			"data":    nil,
+		})
+	} else if user.ID == admin.ID || user.IsAdmin {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Administrators can not be impersonated",
+			"data":    nil,
+		})
+	} else if user.DeactivatedAt != nil {
+		return accountDeactivated(c)
+	}
+
+	//the refresh token is not handed out, the session ends with the access token
+	login := models.Login{
+		UserID:             user.ID,
+		RefreshExpiresAt:   time.Now().Add(config.App.ImpersonationTTL),
+		ClientIP:           c.IP(),
+		UserAgent:          truncate(c.Get(fiber.HeaderUserAgent), 255),
+		ImpersonatorUserID: &admin.ID,
+	}
+	accessToken, _, err := rotateLoginTokens(&login)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not issue access token",
+			"data":    err.Error(),
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&login).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditImpersonateUser, models.AuditTargetUser, user.ID, fiber.Map{
+			"reason":     impersonateInput.Reason,
+			"login_id":   login.ID,
+			"expires_at": login.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseLogin := CreateResponseLogin(login, accessToken, "")
+	return c.Status(200).JSON(responseLogin)
+}
+
+// revokes every session, login challenge and personal access token of a
+// user. the session keepLoginID stays valid, all are revoked when it is 0.
+func revokeUserAccess(tx *gorm.DB, userID uint, keepLoginID uint) error {
+	now := time.Now()
+	if err := tx.Model(&models.Login{}).
+		Where("user_id = ? AND revoked_at IS NULL AND id <> ?", userID, keepLoginID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.LoginChallenge{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// GET All Board, filtered by owner_id and searched by q
+func GetAdminBoards(c *fiber.Ctx) error {
+	boards := []models.Board{}
+
+	query := database.DB.Order("id")
+	if ownerID := c.QueryInt("owner_id"); ownerID > 0 {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("board_name LIKE ?", "%"+q+"%")
+	}
+
+	//read database
+	if err := query.Find(&boards).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	responseBoards := []AdminBoard{}
+
+	for _, board := range boards {
+		responseBoard := createResponseAdminBoard(board)
+		responseBoards = append(responseBoards, responseBoard)
+	}
+	return c.Status(200).JSON(responseBoards)
+}
+
+// POST change the owner of any board
+func ChangeBoardOwner(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	var board models.Board
+
+	//check board id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find Board
+	if err := findBoard(id, &board); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	type ChangeBoardOwner struct {
+		UserID uint `json:"user_id"`
+	}
+
+	var ownerInput ChangeBoardOwner
+
+	//parsing validation
+	if err := c.BodyParser(&ownerInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	if ownerInput.UserID == 0 || ownerInput.UserID == board.OwnerID {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid user id",
+			"data":    nil,
+		})
+	}
+
+	//query to find the new owner
+	var newOwner models.User
+	if err := findUser(int(ownerInput.UserID), &newOwner); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if newOwner.DeactivatedAt != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "New owner is deactivated",
+			"data":    nil,
+		})
+	}
+	if newOwner.EmailVerifiedAt == nil {
+		return unverifiedEmailError(c, newOwner)
+	}
+
+	previousOwnerID := board.OwnerID
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := changeBoardOwner(tx, &board, newOwner.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditChangeBoardOwner, models.AuditTargetBoard, board.ID, fiber.Map{
+			"previous_owner_id": previousOwnerID,
+			"owner_id":          newOwner.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseBoard := createResponseAdminBoard(board)
+	return c.Status(200).JSON(responseBoard)
+}
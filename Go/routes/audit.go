@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"encoding/json"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AuditLog struct {
+	ID              uint            `json:"id"`
+	ActorUserID     *uint           `json:"actor_user_id"`
+	EffectiveUserID *uint           `json:"effective_user_id"`
+	Action          string          `json:"action"`
+	TargetType      string          `json:"target_type"`
+	TargetID        uint            `json:"target_id"`
+	Details         json.RawMessage `json:"details"`
+	ClientIP        string          `json:"client_ip"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+func createResponseAuditLog(auditLog models.AuditLog) AuditLog {
+	details := json.RawMessage(auditLog.Details)
+	if !json.Valid(details) {
+		details = json.RawMessage("null")
+	}
+	return AuditLog{
+		ID:              auditLog.ID,
+		ActorUserID:     auditLog.ActorUserID,
+		EffectiveUserID: auditLog.EffectiveUserID,
+		Action:          auditLog.Action,
+		TargetType:      auditLog.TargetType,
+		TargetID:        auditLog.TargetID,
+		Details:         details,
+		ClientIP:        auditLog.ClientIP,
+		CreatedAt:       auditLog.CreatedAt,
+	}
+}
+
+// records an action of the current user. tx is the transaction of the
+// action, so the action and its audit entry are saved together.
+func recordAudit(c *fiber.Ctx, tx *gorm.DB, action string, targetType string, targetID uint, details fiber.Map) error {
+	actor := middleware.CurrentUser(c)
+
+	//under impersonation the admin is the actor
+	var effectiveUserID *uint
+	if impersonator := middleware.CurrentImpersonator(c); impersonator != nil {
+		effectiveUserID = &actor.ID
+		actor = *impersonator
+	}
+
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.AuditLog{
+		ActorUserID:     &actor.ID,
+		EffectiveUserID: effectiveUserID,
+		Action:          action,
+		TargetType:      targetType,
+		TargetID:        targetID,
+		Details:         string(encoded),
+		ClientIP:        c.IP(),
+	}).Error
+}
+
+// GET audit log, newest first, filtered by actor_id, effective_user_id,
+// action and target
+func GetAuditLogs(c *fiber.Ctx) error {
+	auditLogs := []models.AuditLog{}
+
+	query := database.DB.Order("id desc").Limit(100)
+	if actorID := c.QueryInt("actor_id"); actorID > 0 {
+		query = query.Where("actor_user_id = ?", actorID)
+	}
+	if effectiveID := c.QueryInt("effective_user_id"); effectiveID > 0 {
+		query = query.Where("effective_user_id = ?", effectiveID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.QueryInt("target_id"); targetID > 0 {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if beforeID := c.QueryInt("before_id"); beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	//read database
+	if err := query.Find(&auditLogs).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	responseAuditLogs := []AuditLog{}
+
+	for _, auditLog := range auditLogs {
+		responseAuditLog := createResponseAuditLog(auditLog)
+		responseAuditLogs = append(responseAuditLogs, responseAuditLog)
+	}
+	return c.Status(200).JSON(responseAuditLogs)
+}
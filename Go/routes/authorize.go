@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"errors"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"gofiber/permissions"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errForbidden = errors.New("You do not have permission to perform this action")
+
+// checks that the current user may perform permission on the board, either
+// as the board owner or through the role of their BoardMember row
+func authorizeBoard(c *fiber.Ctx, boardID uint, permission permissions.Permission) error {
+	user := middleware.CurrentUser(c)
+
+	var board models.Board
+	if err := database.DB.First(&board, boardID).Error; err != nil {
+		return errors.New("Board does not exist")
+	}
+	if !tokenAllowsBoard(c, board.ID) {
+		return errForbidden
+	}
+	if board.OwnerID == user.ID {
+		return nil
+	}
+
+	var member models.BoardMember
+	result := database.DB.
+		Where("board_id = ? AND user_id = ?", boardID, user.ID).
+		First(&member)
+	if result.RowsAffected == 0 || !permissions.RoleAllows(member.Role, permission) {
+		return errForbidden
+	}
+	return nil
+}
+
+func isBoardOwner(c *fiber.Ctx, boardID uint) bool {
+	var board models.Board
+	if err := database.DB.First(&board, boardID).Error; err != nil {
+		return false
+	}
+	return board.OwnerID == middleware.CurrentUser(c).ID
+}
+
+// writes the response for an authorizeBoard error
+func authorizeError(c *fiber.Ctx, err error, permission permissions.Permission) error {
+	if errors.Is(err, errForbidden) {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data": fiber.Map{
+				"permission": permission,
+			},
+		})
+	}
+	return c.Status(404).JSON(fiber.Map{
+		"error":   true,
+		"message": err.Error(),
+	})
+}
+
+// reports whether a board-scoped personal access token covers the board
+func tokenAllowsBoard(c *fiber.Ctx, boardID uint) bool {
+	token := middleware.CurrentToken(c)
+	if token == nil || len(token.Boards) == 0 {
+		return true
+	}
+	for _, scope := range token.Boards {
+		if scope.BoardID == boardID {
+			return true
+		}
+	}
+	return false
+}
+
+// subquery of the board ids the current user owns or is a member of,
+// limited to the scopes of the personal access token if there is one
+func accessibleBoardIDs(c *fiber.Ctx) *gorm.DB {
+	user := middleware.CurrentUser(c)
+	boardIDs := database.DB.Raw(
+		"SELECT id FROM boards WHERE owner_id = ? AND deleted_at IS NULL "+
+			"UNION SELECT board_id FROM board_members WHERE user_id = ? AND deleted_at IS NULL",
+		user.ID, user.ID,
+	)
+
+	token := middleware.CurrentToken(c)
+	if token == nil || len(token.Boards) == 0 {
+		return boardIDs
+	}
+	scopes := []uint{}
+	for _, scope := range token.Boards {
+		scopes = append(scopes, scope.BoardID)
+	}
+	return database.DB.Table("(?) AS accessible_boards", boardIDs).
+		Select("id").
+		Where("id IN ?", scopes)
+}
+
+// authorizeBoard for the board the column belongs to
+func authorizeColumn(c *fiber.Ctx, columnBoardID uint, permission permissions.Permission) error {
+	boardID, err := boardIDOfColumn(columnBoardID)
+	if err != nil {
+		return err
+	}
+	return authorizeBoard(c, boardID, permission)
+}
+
+// authorizeBoard for the board the task belongs to
+func authorizeTask(c *fiber.Ctx, taskID uint, permission permissions.Permission) error {
+	boardID, err := boardIDOfTask(taskID)
+	if err != nil {
+		return err
+	}
+	return authorizeBoard(c, boardID, permission)
+}
+
+func boardIDOfColumn(columnBoardID uint) (uint, error) {
+	var columnboard models.ColumnBoard
+	if err := database.DB.First(&columnboard, columnBoardID).Error; err != nil {
+		return 0, errors.New("Column Board does not exist")
+	}
+	return columnboard.BoardID, nil
+}
+
+func boardIDOfTask(taskID uint) (uint, error) {
+	var task models.Task
+	if err := database.DB.First(&task, taskID).Error; err != nil {
+		return 0, errors.New("Task does not exist")
+	}
+	return boardIDOfColumn(task.ColumnBoardID)
+}
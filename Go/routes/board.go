@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"gofiber/database"
+	"gofiber/middleware"
 	"gofiber/models"
+	"gofiber/permissions"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -19,7 +21,7 @@ type Board struct {
 
 func createResponseBoard(board models.Board) Board {
 	return Board{
-		OwnerID:   board.User.ID,
+		OwnerID:   board.OwnerID,
 		BoardName: board.BoardName,
 	}
 }
@@ -56,12 +58,34 @@ func CreateBoard(c *fiber.Ctx) error {
 		})
 	}
 
+	//board-scoped access tokens can not create boards
+	if token := middleware.CurrentToken(c); token != nil && len(token.Boards) > 0 {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": errForbidden.Error(),
+			"data":    nil,
+		})
+	}
+
+	//owner is the authenticated user
+	user := middleware.CurrentUser(c)
+
 	board := models.Board{
 		BoardName: boardInput.BoardName,
-		OwnerID:   boardInput.OwnerID,
+		OwnerID:   user.ID,
 	}
 
-	database.DB.Create(&board)
+	//every board starts with the default columns
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&board).Error; err != nil {
+			return err
+		}
+		columns := models.DefaultColumns(board.ID)
+		return tx.Create(&columns).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
 	responseBoard := createResponseBoard(board)
 	return c.Status(200).JSON(responseBoard)
 }
@@ -70,7 +94,7 @@ func CreateBoard(c *fiber.Ctx) error {
 func GetBoards(c *fiber.Ctx) error {
 	boards := []models.Board{}
 
-	database.DB.Find(&boards)
+	database.DB.Where("id IN (?)", accessibleBoardIDs(c)).Find(&boards)
 	responseBoards := []Board{}
 
 	for _, board := range boards {
@@ -101,6 +125,12 @@ func GetBoardByID(c *fiber.Ctx) error {
 	if err := findBoard(id, &board); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+
+	//check board permission
+	if err := authorizeBoard(c, board.ID, permissions.ViewBoard); err != nil {
+		return authorizeError(c, err, permissions.ViewBoard)
+	}
+
 	responseBoard := createResponseBoard(board)
 	return c.Status(200).JSON(responseBoard)
 }
@@ -120,6 +150,11 @@ func UpdateBoard(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeBoard(c, board.ID, permissions.ManageBoard); err != nil {
+		return authorizeError(c, err, permissions.ManageBoard)
+	}
+
 	type UpdateBoard struct {
 		BoardName string `json:"board_name"`
 	}
@@ -175,6 +210,11 @@ func DeleteBoard(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeBoard(c, board.ID, permissions.ManageBoard); err != nil {
+		return authorizeError(c, err, permissions.ManageBoard)
+	}
+
 	//soft delete
 	if err := database.DB.Delete(&board).Error; err != nil {
 		return c.Status(404).JSON(err.Error())
@@ -182,3 +222,109 @@ func DeleteBoard(c *fiber.Ctx) error {
 
 	return c.Status(200).SendString("Successfully Deleted Board")
 }
+
+// POST transfer ownership
+func TransferBoard(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	user := middleware.CurrentUser(c)
+	var board models.Board
+
+	//check board id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find Board
+	if err := findBoard(id, &board); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	//only the owner can hand the board over
+	if board.OwnerID != user.ID {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": "Only the board owner can transfer ownership",
+			"data":    nil,
+		})
+	}
+
+	type TransferBoard struct {
+		UserID uint `json:"user_id"`
+	}
+
+	var transferData TransferBoard
+
+	//parsing validation
+	if err := c.BodyParser(&transferData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	if transferData.UserID == 0 || transferData.UserID == board.OwnerID {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid user id",
+			"data":    nil,
+		})
+	}
+
+	//new owner must already be a member of the board
+	var newOwner models.BoardMember
+	result := database.DB.
+		Where("board_id = ? AND user_id = ?", board.ID, transferData.UserID).
+		First(&newOwner)
+	if result.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "New owner must be a member of the board",
+		})
+	}
+
+	//the new owner must be able to act on the board
+	var owner models.User
+	if err := findUser(int(transferData.UserID), &owner); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if owner.DeactivatedAt != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "New owner is deactivated",
+			"data":    nil,
+		})
+	}
+	if owner.EmailVerifiedAt == nil {
+		return unverifiedEmailError(c, owner)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return changeBoardOwner(tx, &board, transferData.UserID)
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseBoard := createResponseBoard(board)
+	return c.Status(200).JSON(responseBoard)
+}
+
+// the new owner is implicitly admin, so their membership is removed. the
+// previous owner stays on as admin
+func changeBoardOwner(tx *gorm.DB, board *models.Board, newOwnerID uint) error {
+	if err := tx.Where("board_id = ? AND user_id IN ?", board.ID, []uint{newOwnerID, board.OwnerID}).
+		Delete(&models.BoardMember{}).Error; err != nil {
+		return err
+	}
+	previousOwner := models.BoardMember{
+		BoardID: board.ID,
+		UserID:  board.OwnerID,
+		Role:    permissions.RoleAdmin,
+	}
+	if err := tx.Create(&previousOwner).Error; err != nil {
+		return err
+	}
+	board.OwnerID = newOwnerID
+	return tx.Save(board).Error
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"fmt"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"gofiber/permissions"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTransferBoard(t *testing.T) {
+	setupTestDB(t)
+	app := fiber.New()
+	app.Post("/api/boards/:id/transfer", middleware.Protected(), middleware.SessionOnly(), TransferBoard)
+
+	owner := createTestUser(t, "owner")
+	deactivated := createTestUser(t, "deactivated")
+	now := time.Now()
+	database.DB.Model(&deactivated).Update("deactivated_at", now)
+	unverified := createTestUser(t, "unverified")
+	database.DB.Model(&unverified).Update("email_verified_at", nil)
+	member := createTestUser(t, "member")
+
+	board := models.Board{BoardName: "Board", OwnerID: owner.ID}
+	database.DB.Create(&board)
+	for _, user := range []models.User{deactivated, unverified, member} {
+		database.DB.Create(&models.BoardMember{BoardID: board.ID, UserID: user.ID, Role: permissions.RoleViewer})
+	}
+	token := sessionToken(t, owner)
+	path := fmt.Sprintf("/api/boards/%d/transfer", board.ID)
+
+	tests := []struct {
+		name   string
+		userID uint
+		want   int
+	}{
+		{"deactivated", deactivated.ID, 400},
+		{"unverified email", unverified.ID, 400},
+		{"member", member.ID, 200},
+	}
+	for _, tt := range tests {
+		resp := sendJSON(t, app, "POST", path, token, fiber.Map{"user_id": tt.userID})
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	database.DB.First(&board, board.ID)
+	if board.OwnerID != member.ID {
+		t.Errorf("owner = %d, want %d", board.OwnerID, member.ID)
+	}
+}
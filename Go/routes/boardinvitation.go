@@ -0,0 +1,499 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/mailer"
+	"gofiber/middleware"
+	"gofiber/models"
+	"gofiber/permissions"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errInvitationAnswered = errors.New("Invitation was already answered")
+
+type BoardInvitation struct {
+	ID              uint      `json:"id"`
+	BoardID         uint      `json:"board_id"`
+	BoardName       string    `json:"board_name"`
+	InvitedByUserID uint      `json:"invited_by_user_id"`
+	InviteeUserID   *uint     `json:"invitee_user_id"`
+	Email           string    `json:"email"`
+	Role            string    `json:"role"`
+	Status          string    `json:"status"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// pending invitations past their expiry are shown as expired
+func createResponseBoardInvitation(invitation models.BoardInvitation) BoardInvitation {
+	status := invitation.Status
+	if status == models.InvitationPending && time.Now().After(invitation.ExpiresAt) {
+		status = "expired"
+	}
+	return BoardInvitation{
+		ID:              invitation.ID,
+		BoardID:         invitation.BoardID,
+		BoardName:       invitation.Board.BoardName,
+		InvitedByUserID: invitation.InvitedByUserID,
+		InviteeUserID:   invitation.InviteeUserID,
+		Email:           invitation.Email,
+		Role:            invitation.Role,
+		Status:          status,
+		ExpiresAt:       invitation.ExpiresAt,
+		CreatedAt:       invitation.CreatedAt,
+	}
+}
+
+// POST invite a user to a board by username or email
+func CreateBoardInvitation(c *fiber.Ctx) error {
+	inviter := middleware.CurrentUser(c)
+
+	type CreateBoardInvitation struct {
+		BoardID  uint   `json:"board_id"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+	}
+
+	var invitationInput CreateBoardInvitation
+
+	//parsing validation
+	if err := c.BodyParser(&invitationInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//input validation
+	invitationInput.Email = strings.ToLower(strings.TrimSpace(invitationInput.Email))
+	if (invitationInput.Username == "") == (invitationInput.Email == "") {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Either username or email is required",
+			"data":    nil,
+		})
+	} else if invitationInput.Email != "" && !strings.Contains(invitationInput.Email, "@") {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid email",
+			"data":    nil,
+		})
+	}
+
+	//check if board exists
+	var board models.Board
+	if err := database.DB.First(&board, invitationInput.BoardID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": "Board not found",
+		})
+	}
+
+	//check board permission
+	if err := authorizeBoard(c, board.ID, permissions.ManageMember); err != nil {
+		return authorizeError(c, err, permissions.ManageMember)
+	}
+
+	//validate role input
+	if !permissions.ValidRole(invitationInput.Role) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid role. Must be one of: preparer, reviewer, viewer, admin",
+		})
+	}
+
+	//only the owner can delegate the admin role
+	if invitationInput.Role == permissions.RoleAdmin && !isBoardOwner(c, board.ID) {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": "Only the board owner can grant or revoke the admin role",
+			"data":    nil,
+		})
+	}
+
+	//check there is no reviewer yet
+	if invitationInput.Role == permissions.RoleReviewer && boardHasReviewer(board.ID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "This board already has a reviewer",
+		})
+	}
+
+	invitation := models.BoardInvitation{
+		BoardID:         board.ID,
+		InvitedByUserID: inviter.ID,
+		Email:           invitationInput.Email,
+		Role:            invitationInput.Role,
+		Status:          models.InvitationPending,
+		ExpiresAt:       time.Now().Add(config.App.BoardInvitationTTL),
+	}
+
+	//an invite by username goes to that account
+	recipient := invitationInput.Email
+	var invitee models.User
+	if invitationInput.Username != "" {
+		result := database.DB.
+			Where("username = ?", invitationInput.Username).
+			First(&invitee)
+		if result.RowsAffected == 0 {
+			return c.Status(404).JSON(fiber.Map{
+				"error":   true,
+				"message": "User not found",
+			})
+		}
+		if invitee.DeactivatedAt != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": "This user is deactivated",
+			})
+		}
+		if err := checkNotOnBoard(board, invitee.ID); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+		invitation.InviteeUserID = &invitee.ID
+		recipient = invitee.Email
+	} else if emailOnlyDeactivated(invitationInput.Email) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "This user is deactivated",
+		})
+	}
+
+	//check there is no pending invitation for the same person
+	var count int64
+	duplicate := database.DB.Model(&models.BoardInvitation{}).
+		Where("board_id = ? AND status = ? AND expires_at > ?", board.ID, models.InvitationPending, time.Now())
+	if invitation.InviteeUserID != nil {
+		duplicate = duplicate.Where("invitee_user_id = ?", invitee.ID)
+	} else {
+		duplicate = duplicate.Where("email = ?", invitation.Email)
+	}
+	duplicate.Count(&count)
+	if count > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "This user already has a pending invitation to the board",
+		})
+	}
+
+	//insert database
+	if err := database.DB.Create(&invitation).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	invitation.Board = board
+
+	//the invitation stands even if the email can not be sent, the invitee
+	//sees it in their pending invitations
+	if err := sendBoardInvitationEmail(invitation, inviter, recipient); err != nil {
+		log.Printf("invitation email for invitation %d: %v", invitation.ID, err)
+	}
+
+	responseInvitation := createResponseBoardInvitation(invitation)
+	return c.Status(200).JSON(responseInvitation)
+}
+
+// GET pending BoardInvitation of the current user, or every BoardInvitation
+// of a board with ?board_id=
+func GetBoardInvitations(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+	invitations := []models.BoardInvitation{}
+
+	query := database.DB.Preload("Board").Order("created_at desc")
+	if boardID := c.QueryInt("board_id"); boardID > 0 {
+		//check board permission
+		if err := authorizeBoard(c, uint(boardID), permissions.ManageMember); err != nil {
+			return authorizeError(c, err, permissions.ManageMember)
+		}
+		query = query.Where("board_id = ?", boardID)
+	} else {
+		query = invitedUser(query, user).
+			Where("status = ? AND expires_at > ?", models.InvitationPending, time.Now())
+	}
+
+	//read database
+	query.Find(&invitations)
+	responseInvitations := []BoardInvitation{}
+
+	for _, invitation := range invitations {
+		responseInvitation := createResponseBoardInvitation(invitation)
+		responseInvitations = append(responseInvitations, responseInvitation)
+	}
+	return c.Status(200).JSON(responseInvitations)
+}
+
+// query to find BoardInvitation in DB
+func findBoardInvitation(id int, invitation *models.BoardInvitation) error {
+	database.DB.Preload("Board").First(&invitation, "id=?", id)
+	if invitation.ID == 0 {
+		return errors.New("Board Invitation does not exist")
+	}
+	return nil
+}
+
+// GET by ID, for the invitee and the board managers
+func GetBoardInvitationByID(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	var invitation models.BoardInvitation
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//read database
+	if err := findBoardInvitation(id, &invitation); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	if !isInvitee(invitation, middleware.CurrentUser(c)) {
+		//check board permission
+		if err := authorizeBoard(c, invitation.BoardID, permissions.ManageMember); err != nil {
+			return authorizeError(c, err, permissions.ManageMember)
+		}
+	}
+
+	responseInvitation := createResponseBoardInvitation(invitation)
+	return c.Status(200).JSON(responseInvitation)
+}
+
+// POST accept, the current user joins the board with the proposed role
+func AcceptBoardInvitation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	user := middleware.CurrentUser(c)
+	var invitation models.BoardInvitation
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find a pending invitation of the current user
+	if err := findPendingInvitation(id, user, &invitation); err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	//check user has verified their email
+	if user.EmailVerifiedAt == nil {
+		return unverifiedEmailError(c, user)
+	}
+
+	//the board may have changed since the invitation was sent
+	if err := checkNotOnBoard(invitation.Board, user.ID); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+	if invitation.Role == permissions.RoleReviewer && boardHasReviewer(invitation.BoardID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "This board already has a reviewer",
+		})
+	}
+
+	member := models.BoardMember{
+		BoardID: invitation.BoardID,
+		UserID:  user.ID,
+		Role:    invitation.Role,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := answerInvitation(tx, &invitation, user, models.InvitationAccepted); err != nil {
+			return err
+		}
+		return tx.Create(&member).Error
+	})
+	if err == errInvitationAnswered {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	member.Board = invitation.Board
+	responseBoardMember := createResponseBoardMember(member)
+	return c.Status(200).JSON(responseBoardMember)
+}
+
+// POST decline
+func DeclineBoardInvitation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	user := middleware.CurrentUser(c)
+	var invitation models.BoardInvitation
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find a pending invitation of the current user
+	if err := findPendingInvitation(id, user, &invitation); err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	err = answerInvitation(database.DB, &invitation, user, models.InvitationDeclined)
+	if err == errInvitationAnswered {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseInvitation := createResponseBoardInvitation(invitation)
+	return c.Status(200).JSON(responseInvitation)
+}
+
+// DELETE revoke a pending invitation
+func DeleteBoardInvitation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	var invitation models.BoardInvitation
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find BoardInvitation
+	if err := findBoardInvitation(id, &invitation); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	//check board permission
+	if err := authorizeBoard(c, invitation.BoardID, permissions.ManageMember); err != nil {
+		return authorizeError(c, err, permissions.ManageMember)
+	}
+
+	revoked := database.DB.Model(&models.BoardInvitation{}).
+		Where("id = ? AND status = ?", invitation.ID, models.InvitationPending).
+		Update("status", models.InvitationRevoked)
+	if revoked.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": errInvitationAnswered.Error(),
+		})
+	}
+
+	return c.Status(200).SendString("Successfully Revoked Board Invitation")
+}
+
+// restricts query to the invitations addressed to user, by account or by
+// email. an email only counts once the user has verified it
+func invitedUser(query *gorm.DB, user models.User) *gorm.DB {
+	if user.EmailVerifiedAt == nil {
+		return query.Where("invitee_user_id = ?", user.ID)
+	}
+	return query.Where("invitee_user_id = ? OR (invitee_user_id IS NULL AND email = ?)",
+		user.ID, strings.ToLower(user.Email))
+}
+
+func isInvitee(invitation models.BoardInvitation, user models.User) bool {
+	if invitation.InviteeUserID != nil {
+		return *invitation.InviteeUserID == user.ID
+	}
+	return user.EmailVerifiedAt != nil && strings.EqualFold(invitation.Email, user.Email)
+}
+
+func findPendingInvitation(id int, user models.User, invitation *models.BoardInvitation) error {
+	result := invitedUser(database.DB.Preload("Board"), user).
+		Where("id = ? AND status = ? AND expires_at > ?", id, models.InvitationPending, time.Now()).
+		First(invitation)
+	if result.RowsAffected == 0 {
+		return errors.New("Invitation not found or expired")
+	}
+	return nil
+}
+
+// moves a pending invitation to status, only one answer can win
+func answerInvitation(tx *gorm.DB, invitation *models.BoardInvitation, user models.User, status string) error {
+	now := time.Now()
+	answered := tx.Model(&models.BoardInvitation{}).
+		Where("id = ? AND status = ?", invitation.ID, models.InvitationPending).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"invitee_user_id": user.ID,
+			"responded_at":    now,
+		})
+	if answered.Error != nil {
+		return answered.Error
+	}
+	if answered.RowsAffected == 0 {
+		return errInvitationAnswered
+	}
+	invitation.Status = status
+	invitation.InviteeUserID = &user.ID
+	invitation.RespondedAt = &now
+	return nil
+}
+
+// the owner is implicitly admin, and members already have a role
+func checkNotOnBoard(board models.Board, userID uint) error {
+	if userID == board.OwnerID {
+		return errors.New("The board owner is already an admin of this board")
+	}
+	var count int64
+	database.DB.Model(&models.BoardMember{}).
+		Where("board_id = ? AND user_id = ?", board.ID, userID).
+		Count(&count)
+	if count > 0 {
+		return errors.New("This user is already a member of the board")
+	}
+	return nil
+}
+
+func boardHasReviewer(boardID uint) bool {
+	var count int64
+	database.DB.Model(&models.BoardMember{}).
+		Where("board_id = ? AND role = ?", boardID, permissions.RoleReviewer).
+		Count(&count)
+	return count > 0
+}
+
+// whether the email belongs to deactivated accounts only, they could never
+// accept an invitation sent to it
+func emailOnlyDeactivated(email string) bool {
+	var active, deactivated int64
+	database.DB.Model(&models.User{}).
+		Where("email = ? AND deactivated_at IS NULL", email).
+		Count(&active)
+	database.DB.Model(&models.User{}).
+		Where("email = ? AND deactivated_at IS NOT NULL", email).
+		Count(&deactivated)
+	return active == 0 && deactivated > 0
+}
+
+// tells the invitee about the invitation, people without an account are
+// asked to sign up with the invited address
+func sendBoardInvitationEmail(invitation models.BoardInvitation, inviter models.User, to string) error {
+	link := fmt.Sprintf("%s/invitations", config.App.AppBaseURL)
+	action := "You can accept or decline it here:"
+	if invitation.InviteeUserID == nil {
+		action = "Sign up or log in with this email address to accept or decline it:"
+	}
+
+	return mailer.Default.Send(mailer.Message{
+		To:      to,
+		Subject: fmt.Sprintf("Invitation to the board %s", invitation.Board.BoardName),
+		Body: fmt.Sprintf("Hi,\n\n%s invited you to the board %s as %s. The invitation expires on %s.\n\n%s\n\n%s\n",
+			inviter.Username, invitation.Board.BoardName, invitation.Role,
+			invitation.ExpiresAt.Format("2 January 2006"), action, link),
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"gofiber/database"
 	"gofiber/models"
+	"gofiber/permissions"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -27,92 +28,12 @@ func createResponseBoardMember(boardmember models.BoardMember) BoardMember {
 	}
 }
 
-// Post
-func CreateBoardMember(c *fiber.Ctx) error {
-	var boardMemberInput models.BoardMember
-
-	//parsing validation
-	if err := c.BodyParser(&boardMemberInput); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid request body",
-			"data":    err.Error(),
-		})
-	}
-
-	//input validation
-	if boardMemberInput.Role == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid role",
-			"data":    nil,
-		})
-	}
-
-	//check if board exists
-	var board models.Board
-	if err := database.DB.First(&board, boardMemberInput.BoardID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error":   true,
-			"message": "Board not found",
-		})
-	}
-
-	//check if user exists
-	var user models.User
-	if err := database.DB.First(&user, boardMemberInput.UserID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error":   true,
-			"message": "User not found",
-		})
-	}
-
-	//validate role input
-	validRoles := map[string]bool{
-		"preparer": true,
-		"reviewer": true,
-		"viewer":   true,
-	}
-	if !validRoles[boardMemberInput.Role] {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid role. Must be one of: preparer, reviewer, viewer",
-		})
-	}
-
-	//check assigning reviewer and ensure only one exists
-	if boardMemberInput.Role == "reviewer" {
-		var existingReviewer models.BoardMember
-		err := database.DB.
-			Where("board_id = ? AND role = ?", boardMemberInput.BoardID, "reviewer").
-			First(&existingReviewer).Error
-
-		if err == nil {
-			return c.Status(400).JSON(fiber.Map{
-				"error":   true,
-				"message": "This board already has a reviewer",
-			})
-		}
-	}
-
-	newBoard := models.BoardMember{
-		BoardID: boardMemberInput.BoardID,
-		UserID:  boardMemberInput.UserID,
-		Role:    boardMemberInput.Role,
-	}
-
-	//insert database
-	database.DB.Create(&newBoard)
-	responseBoardMember := createResponseBoardMember(newBoard)
-	return c.Status(200).JSON(responseBoardMember)
-}
-
 // GET All BoardMember
 func GetBoardMembers(c *fiber.Ctx) error {
 	boardMemberInput := []models.BoardMember{}
 
 	//read database
-	database.DB.Find(&boardMemberInput)
+	database.DB.Where("board_id IN (?)", accessibleBoardIDs(c)).Find(&boardMemberInput)
 	responseBoardMembers := []BoardMember{}
 
 	for _, boardmember := range boardMemberInput {
@@ -144,6 +65,12 @@ func GetBoardMemberByID(c *fiber.Ctx) error {
 	if err := findBoardMember(id, &boardMemberInput); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+
+	//check board permission
+	if err := authorizeBoard(c, boardMemberInput.BoardID, permissions.ViewMember); err != nil {
+		return authorizeError(c, err, permissions.ViewMember)
+	}
+
 	responseBoardMember := createResponseBoardMember(boardMemberInput)
 	return c.Status(200).JSON(responseBoardMember)
 }
@@ -163,6 +90,11 @@ func UpdateBoardMember(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeBoard(c, boardMemberInput.BoardID, permissions.ManageMember); err != nil {
+		return authorizeError(c, err, permissions.ManageMember)
+	}
+
 	type UpdateBoardMember struct {
 		Role string `json:"role"`
 	}
@@ -175,15 +107,19 @@ func UpdateBoardMember(c *fiber.Ctx) error {
 	}
 
 	//validate role input
-	validRoles := map[string]bool{
-		"preparer": true,
-		"reviewer": true,
-		"viewer":   true,
-	}
-	if !validRoles[updateData.Role] {
+	if !permissions.ValidRole(updateData.Role) {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid role. Must be one of: preparer, reviewer, viewer",
+			"message": "Invalid role. Must be one of: preparer, reviewer, viewer, admin",
+		})
+	}
+
+	//only the owner can delegate the admin role
+	if (updateData.Role == permissions.RoleAdmin || boardMemberInput.Role == permissions.RoleAdmin) && !isBoardOwner(c, boardMemberInput.BoardID) {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": "Only the board owner can grant or revoke the admin role",
+			"data":    nil,
 		})
 	}
 
@@ -230,6 +166,20 @@ func DeleteBoardMember(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeBoard(c, boardMemberInput.BoardID, permissions.ManageMember); err != nil {
+		return authorizeError(c, err, permissions.ManageMember)
+	}
+
+	//only the owner can delegate the admin role
+	if boardMemberInput.Role == permissions.RoleAdmin && !isBoardOwner(c, boardMemberInput.BoardID) {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": "Only the board owner can grant or revoke the admin role",
+			"data":    nil,
+		})
+	}
+
 	//soft delete
 	if err := database.DB.Delete(&boardMemberInput).Error; err != nil {
 		return c.Status(404).JSON(err.Error())
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"gofiber/database"
 	"gofiber/models"
+	"gofiber/permissions"
+	"gofiber/validation"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -14,19 +17,34 @@ type ColumnBoard struct {
 	gorm.Model
 	Board      Board
 	ColumnName string `json:"column_name"`
+	Color      string `json:"color"`
+	Position   int    `json:"position"`
+	IsDone     bool   `json:"is_done"`
 	BoardID    uint   `json:"board_id"`
 }
 
 func createResponseColumnBoard(columnboard models.ColumnBoard) ColumnBoard {
 	return ColumnBoard{
-		BoardID:    columnboard.Board.ID,
+		Model:      columnboard.Model,
+		BoardID:    columnboard.BoardID,
 		ColumnName: columnboard.ColumnName,
+		Color:      columnboard.Color,
+		Position:   columnboard.Position,
+		IsDone:     columnboard.IsDone,
 	}
 }
 
 // POST
 func CreateColumnBoard(c *fiber.Ctx) error {
-	var columnboardInput models.ColumnBoard
+	type CreateColumnBoard struct {
+		BoardID    uint   `json:"board_id"`
+		ColumnName string `json:"column_name"`
+		Color      string `json:"color"`
+		Position   *int   `json:"position"`
+		IsDone     bool   `json:"is_done"`
+	}
+
+	var columnboardInput CreateColumnBoard
 
 	//parsing validation
 	if err := c.BodyParser(&columnboardInput); err != nil {
@@ -46,47 +64,90 @@ func CreateColumnBoard(c *fiber.Ctx) error {
 		})
 	}
 
+	//check board permission
+	if err := authorizeBoard(c, board.ID, permissions.ManageColumn); err != nil {
+		return authorizeError(c, err, permissions.ManageColumn)
+	}
+
 	//validate column input
-	validColumns := map[string]bool{
-		"To Do":    true,
-		"Doing":    true,
-		"Done":     true,
-		"Accepted": true,
+	newColumn := models.ColumnBoard{
+		BoardID:    board.ID,
+		ColumnName: strings.TrimSpace(columnboardInput.ColumnName),
+		Color:      columnboardInput.Color,
+		IsDone:     columnboardInput.IsDone,
 	}
-	if !validColumns[columnboardInput.ColumnName] {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid column. Must be one of: To Do, Doing, Done, Accepted",
-		})
+	if newColumn.Color == "" {
+		newColumn.Color = models.DefaultColumnColor
+	}
+	//new columns go to the end of the board by default
+	position := -1
+	if columnboardInput.Position != nil {
+		position = *columnboardInput.Position
+		newColumn.Position = position
+	}
+	if err := validateColumn(newColumn); err != nil {
+		return validationError(c, err)
 	}
 
-	//input validation
-	result := database.DB.
-		Where("board_id = ? AND column_name = ?", columnboardInput.BoardID, columnboardInput.ColumnName).
-		Find(&columnboardInput)
-	if result.RowsAffected > 0 {
+	//insert database, the other columns make room at its position
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, board.ID); err != nil {
+			return err
+		}
+		if err := checkColumnName(tx, newColumn); err != nil {
+			return err
+		}
+		if position < 0 {
+			position = len(columnOrder(tx, board.ID))
+		}
+		newColumn.Position = position
+		if err := tx.Create(&newColumn).Error; err != nil {
+			return err
+		}
+		var err error
+		newColumn.Position, err = placeColumn(tx, board.ID, newColumn.ID, position)
+		return err
+	})
+	if err == errColumnExists {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "This column already exists in the board",
+			"message": err.Error(),
 		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
 	}
 
-	newColumn := models.ColumnBoard{
-		BoardID:    columnboardInput.BoardID,
-		ColumnName: columnboardInput.ColumnName,
-	}
-	//insert database
-	database.DB.Create(&newColumn)
 	responseColumnBoard := createResponseColumnBoard(newColumn)
 	return c.Status(200).JSON(responseColumnBoard)
 }
 
+// checks the settings of a column and returns every rule that failed
+func validateColumn(column models.ColumnBoard) error {
+	errs := validation.Errors{}
+	for _, err := range []error{
+		validation.ValidateColumnName(column.ColumnName),
+		validation.ValidateColor(column.Color),
+		validation.ValidatePosition(column.Position),
+	} {
+		if fieldErr, ok := err.(*validation.FieldError); ok {
+			errs = append(errs, fieldErr)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
 // GET All BoardMember
 func GetColumnBoards(c *fiber.Ctx) error {
 	columnboardInput := []models.ColumnBoard{}
 
 	//read database
-	database.DB.Find(&columnboardInput)
+	database.DB.
+		Where("board_id IN (?)", accessibleBoardIDs(c)).
+		Order("board_id, position, id").
+		Find(&columnboardInput)
 	responseColumnBoards := []ColumnBoard{}
 
 	for _, columnboard := range columnboardInput {
@@ -118,6 +179,11 @@ func GetColumnBoardByID(c *fiber.Ctx) error {
 	if err := findColumnBoard(id, &columnboard); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+
+	//check board permission
+	if err := authorizeBoard(c, columnboard.BoardID, permissions.ViewColumn); err != nil {
+		return authorizeError(c, err, permissions.ViewColumn)
+	}
 	responseColumnBoard := createResponseColumnBoard(columnboard)
 	return c.Status(200).JSON(responseColumnBoard)
 }
@@ -137,8 +203,16 @@ func UpdateColumnBoard(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeBoard(c, columnboardInput.BoardID, permissions.ManageColumn); err != nil {
+		return authorizeError(c, err, permissions.ManageColumn)
+	}
+
 	type UpdateColumnBoard struct {
 		ColumnName string `json:"column_name"`
+		Color      string `json:"color"`
+		Position   *int   `json:"position"`
+		IsDone     *bool  `json:"is_done"`
 	}
 
 	var updateData UpdateColumnBoard
@@ -148,41 +222,52 @@ func UpdateColumnBoard(c *fiber.Ctx) error {
 		return c.Status(500).JSON(err.Error())
 	}
 
-	//validate role input
-	validColumns := map[string]bool{
-		"To Do":    true,
-		"Doing":    true,
-		"Done":     true,
-		"Accepted": true,
+	//null validation - if null, data is still the same
+	if name := strings.TrimSpace(updateData.ColumnName); name != "" {
+		columnboardInput.ColumnName = name
 	}
-	if !validColumns[updateData.ColumnName] {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid column. Must be one of: To Do, Doing, Done, Accepted",
-		})
+	if updateData.Color != "" {
+		columnboardInput.Color = updateData.Color
+	}
+	if updateData.Position != nil {
+		columnboardInput.Position = *updateData.Position
+	}
+	if updateData.IsDone != nil {
+		columnboardInput.IsDone = *updateData.IsDone
+	}
+	if err := validateColumn(columnboardInput); err != nil {
+		return validationError(c, err)
 	}
 
-	//input validation
-	var existingBoard models.ColumnBoard
-	result := database.DB.
-		Where("board_id = ? AND column_name = ? AND id != ?", columnboardInput.BoardID, updateData.ColumnName, columnboardInput.ID).
-		First(&existingBoard)
-
-	if result.RowsAffected > 0 {
+	//update database, the columns in between shift when it moves
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, columnboardInput.BoardID); err != nil {
+			return err
+		}
+		if err := checkColumnName(tx, columnboardInput); err != nil {
+			return err
+		}
+		if err := tx.Model(&columnboardInput).
+			Select("ColumnName", "Color", "IsDone").
+			Updates(&columnboardInput).Error; err != nil {
+			return err
+		}
+		if updateData.Position == nil {
+			return nil
+		}
+		var err error
+		columnboardInput.Position, err = placeColumn(tx, columnboardInput.BoardID, columnboardInput.ID, *updateData.Position)
+		return err
+	})
+	if err == errColumnExists {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "This column already exists in the board",
+			"message": err.Error(),
 		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
 	}
 
-	//null validation - if null, data is still the same
-	if updateData.ColumnName != "" {
-		columnboardInput.ColumnName = updateData.ColumnName
-	}
-
-	//update database
-	database.DB.Save(&columnboardInput)
-
 	responseColumnBoard := createResponseColumnBoard(columnboardInput)
 	return c.Status(200).JSON(responseColumnBoard)
 }
@@ -203,10 +288,101 @@ func DeleteColumnBoard(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
-	//soft delete
-	if err := database.DB.Delete(&columnboardInput).Error; err != nil {
+	//check board permission
+	if err := authorizeBoard(c, columnboardInput.BoardID, permissions.ManageColumn); err != nil {
+		return authorizeError(c, err, permissions.ManageColumn)
+	}
+
+	//soft delete, the columns after it close the gap
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, columnboardInput.BoardID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&columnboardInput).Error; err != nil {
+			return err
+		}
+		return setColumnOrder(tx, columnboardInput.BoardID, columnOrder(tx, columnboardInput.BoardID))
+	})
+	if err != nil {
 		return c.Status(404).JSON(err.Error())
 	}
 
 	return c.Status(200).SendString("Successfully Deleted Column Board")
 }
+
+// PUT the order of every column of a board at once
+func ReorderColumnBoards(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	var board models.Board
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	if err := findBoard(id, &board); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	//check board permission
+	if err := authorizeBoard(c, board.ID, permissions.ManageColumn); err != nil {
+		return authorizeError(c, err, permissions.ManageColumn)
+	}
+
+	type ReorderColumnBoards struct {
+		ColumnIDs []uint `json:"column_ids"`
+	}
+
+	var reorderInput ReorderColumnBoards
+
+	//parsing validation
+	if err := c.BodyParser(&reorderInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//the list must name every column of the board exactly once, checked
+	//under the lock so a column added meanwhile is not left out
+	columns := []models.ColumnBoard{}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, board.ID); err != nil {
+			return err
+		}
+		current := map[uint]bool{}
+		for _, columnID := range columnOrder(tx, board.ID) {
+			current[columnID] = true
+		}
+		seen := map[uint]bool{}
+		for _, columnID := range reorderInput.ColumnIDs {
+			if !current[columnID] || seen[columnID] {
+				return errInvalidColumnOrder
+			}
+			seen[columnID] = true
+		}
+		if len(seen) != len(current) {
+			return errInvalidColumnOrder
+		}
+
+		if err := setColumnOrder(tx, board.ID, reorderInput.ColumnIDs); err != nil {
+			return err
+		}
+		return tx.Where("board_id = ?", board.ID).Order("position").Find(&columns).Error
+	})
+	if err == errInvalidColumnOrder {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	responseColumnBoards := []ColumnBoard{}
+	for _, columnboard := range columns {
+		responseColumnBoards = append(responseColumnBoards, createResponseColumnBoard(columnboard))
+	}
+	return c.Status(200).JSON(responseColumnBoards)
+}
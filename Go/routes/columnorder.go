@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"errors"
+	"gofiber/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errColumnExists       = errors.New("This column already exists in the board")
+	errInvalidColumnOrder = errors.New("Column order must list every column of the board exactly once")
+)
+
+// locks the board row until the end of tx, so concurrent edits of the
+// columns of a board run one after another and positions stay dense
+func lockBoard(tx *gorm.DB, boardID uint) error {
+	var board models.Board
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&board, boardID).Error
+}
+
+// ids of the columns of the board in their current order
+func columnOrder(tx *gorm.DB, boardID uint) []uint {
+	var ids []uint
+	tx.Model(&models.ColumnBoard{}).
+		Where("board_id = ?", boardID).
+		Order("position, id").
+		Pluck("id", &ids)
+	return ids
+}
+
+// numbers the columns of the board 0, 1, 2... in the order of ids, only the
+// columns that moved are written
+func setColumnOrder(tx *gorm.DB, boardID uint, ids []uint) error {
+	for position, id := range ids {
+		err := tx.Model(&models.ColumnBoard{}).
+			Where("id = ? AND board_id = ? AND position != ?", id, boardID, position).
+			Update("position", position).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// puts columnID at position in the order of the board, a position past the
+// end puts it last
+func placeColumn(tx *gorm.DB, boardID uint, columnID uint, position int) (int, error) {
+	ids := []uint{}
+	for _, id := range columnOrder(tx, boardID) {
+		if id != columnID {
+			ids = append(ids, id)
+		}
+	}
+	position = min(max(position, 0), len(ids))
+	ids = append(ids[:position], append([]uint{columnID}, ids[position:]...)...)
+	return position, setColumnOrder(tx, boardID, ids)
+}
+
+func checkColumnName(tx *gorm.DB, column models.ColumnBoard) error {
+	var count int64
+	tx.Model(&models.ColumnBoard{}).
+		Where("board_id = ? AND column_name = ? AND id != ?", column.BoardID, column.ColumnName, column.ID).
+		Count(&count)
+	if count > 0 {
+		return errColumnExists
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package routes
+
+import (
+	"errors"
+	"gofiber/database"
+	"gofiber/models"
+	"gofiber/permissions"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// results of a HandoverAssignment
+const (
+	handoverReassigned = "reassigned"
+	// the successor was already assigned to the task, the assignment of
+	// the deactivated user was removed
+	handoverMerged = "merged"
+)
+
+var errAlreadyDeactivated = errors.New("Account is already deactivated")
+
+var (
+	errLastAdminDeactivated = errors.New("The last administrator can not be deactivated")
+	errLastAdminRevoked     = errors.New("The last administrator can not be revoked")
+)
+
+type HandoverBoard struct {
+	BoardID   uint   `json:"board_id"`
+	BoardName string `json:"board_name"`
+}
+
+type HandoverAssignment struct {
+	TaskAssigneeID uint   `json:"task_assignee_id"`
+	TaskID         uint   `json:"task_id"`
+	Result         string `json:"result"`
+}
+
+// Handover reports what was transferred to the successor when an account
+// was deactivated. Memberships lists the boards the successor was added to
+// as preparer to work on the assignments they took over.
+type Handover struct {
+	UserID          uint                 `json:"user_id"`
+	SuccessorUserID *uint                `json:"successor_user_id"`
+	DeactivatedAt   time.Time            `json:"deactivated_at"`
+	Boards          []HandoverBoard      `json:"boards"`
+	Assignments     []HandoverAssignment `json:"assignments"`
+	Memberships     []HandoverBoard      `json:"memberships"`
+}
+
+// deactivates user after handing their boards and open assignments over to
+// a successor, and answers with the handover report. the user keeps their
+// memberships and the tasks they created, so the history stays readable.
+func deactivateUser(c *fiber.Ctx, user models.User) error {
+	if user.DeactivatedAt != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": errAlreadyDeactivated.Error(),
+			"data":    nil,
+		})
+	}
+
+	type DeactivateUser struct {
+		SuccessorUserID uint   `json:"successor_user_id"`
+		Reason          string `json:"reason"`
+	}
+
+	var deactivateInput DeactivateUser
+
+	//parsing validation, the body is optional when there is nothing to hand over
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&deactivateInput); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": "Invalid request body",
+				"data":    err.Error(),
+			})
+		}
+	}
+
+	//successor validation
+	boards, assignments := handoverOf(database.DB, user.ID)
+	var successor *models.User
+	if deactivateInput.SuccessorUserID != 0 {
+		var err error
+		if successor, err = findSuccessor(user, deactivateInput.SuccessorUserID); err != nil {
+			if errors.Is(err, errUnverifiedSuccessor) {
+				return unverifiedEmailError(c, *successor)
+			}
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+	} else if len(boards) > 0 || len(assignments) > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "A successor is required to take over the boards and assignments of this user",
+			"data": fiber.Map{
+				"boards":      len(boards),
+				"assignments": len(assignments),
+			},
+		})
+	}
+
+	var handover Handover
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		//there must always be an active administrator
+		if last, err := isLastAdmin(tx, user.ID); err != nil {
+			return err
+		} else if last {
+			return errLastAdminDeactivated
+		}
+
+		var err error
+		if handover, err = handOver(tx, user, successor); err != nil {
+			return err
+		}
+
+		boardIDs := []uint{}
+		for _, board := range handover.Boards {
+			boardIDs = append(boardIDs, board.BoardID)
+		}
+		taskAssigneeIDs := []uint{}
+		for _, assignment := range handover.Assignments {
+			taskAssigneeIDs = append(taskAssigneeIDs, assignment.TaskAssigneeID)
+		}
+		membershipBoardIDs := []uint{}
+		for _, board := range handover.Memberships {
+			membershipBoardIDs = append(membershipBoardIDs, board.BoardID)
+		}
+		return recordAudit(c, tx, models.AuditDeactivateUser, models.AuditTargetUser, user.ID, fiber.Map{
+			"reason":               deactivateInput.Reason,
+			"successor_user_id":    handover.SuccessorUserID,
+			"board_ids":            boardIDs,
+			"task_assignee_ids":    taskAssigneeIDs,
+			"membership_board_ids": membershipBoardIDs,
+		})
+	})
+	if err == errAlreadyDeactivated || err == errLastAdminDeactivated {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(handover)
+}
+
+// locks the active administrators and reports whether userID is the only
+// one. concurrent demotions and deactivations wait for each other, so they
+// can not remove the last two administrators at once.
+func isLastAdmin(tx *gorm.DB, userID uint) (bool, error) {
+	adminIDs := []uint{}
+	err := tx.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("is_admin = ? AND deactivated_at IS NULL", true).
+		Pluck("id", &adminIDs).Error
+	if err != nil {
+		return false, err
+	}
+	return len(adminIDs) == 1 && adminIDs[0] == userID, nil
+}
+
+var errUnverifiedSuccessor = errors.New("Successor has not verified their email address")
+
+// the successor must be another active account with a verified email
+func findSuccessor(user models.User, successorID uint) (*models.User, error) {
+	var successor models.User
+	if err := findUser(int(successorID), &successor); err != nil {
+		return nil, errors.New("Successor does not exist")
+	}
+	if successor.ID == user.ID {
+		return nil, errors.New("A user can not be their own successor")
+	}
+	if successor.DeactivatedAt != nil {
+		return nil, errors.New("Successor is deactivated")
+	}
+	if successor.EmailVerifiedAt == nil {
+		return &successor, errUnverifiedSuccessor
+	}
+	return &successor, nil
+}
+
+// boards owned by the user and their assignments on open tasks, tasks that
+// still exist and are not in a done column
+func handoverOf(tx *gorm.DB, userID uint) ([]models.Board, []models.TaskAssignee) {
+	boards := []models.Board{}
+	tx.Where("owner_id = ?", userID).Order("id").Find(&boards)
+
+	assignments := []models.TaskAssignee{}
+	tx.Joins("JOIN tasks ON tasks.id = task_assignees.task_id AND tasks.deleted_at IS NULL").
+		Joins("JOIN column_boards ON column_boards.id = tasks.column_board_id AND column_boards.deleted_at IS NULL AND column_boards.is_done = ?", false).
+		Joins("JOIN boards ON boards.id = column_boards.board_id AND boards.deleted_at IS NULL").
+		Where("task_assignees.user_id = ?", userID).
+		Order("task_assignees.id").
+		Find(&assignments)
+
+	return boards, assignments
+}
+
+// marks the user deactivated, logs them out everywhere and moves their
+// boards and assignments to the successor
+func handOver(tx *gorm.DB, user models.User, successor *models.User) (Handover, error) {
+	now := time.Now()
+	handover := Handover{
+		UserID:        user.ID,
+		DeactivatedAt: now,
+		Boards:        []HandoverBoard{},
+		Assignments:   []HandoverAssignment{},
+		Memberships:   []HandoverBoard{},
+	}
+
+	deactivated := tx.Model(&models.User{}).
+		Where("id = ? AND deactivated_at IS NULL", user.ID).
+		Update("deactivated_at", now)
+	if deactivated.Error != nil {
+		return handover, deactivated.Error
+	}
+	if deactivated.RowsAffected == 0 {
+		return handover, errAlreadyDeactivated
+	}
+	if err := revokeUserAccess(tx, user.ID, 0); err != nil {
+		return handover, err
+	}
+
+	boards, assignments := handoverOf(tx, user.ID)
+	if successor == nil {
+		if len(boards) > 0 || len(assignments) > 0 {
+			return handover, errors.New("A successor is required to take over the boards and assignments of this user")
+		}
+		return handover, nil
+	}
+	handover.SuccessorUserID = &successor.ID
+
+	//the previous owner stays on the board as admin, like a transfer
+	for _, board := range boards {
+		if err := changeBoardOwner(tx, &board, successor.ID); err != nil {
+			return handover, err
+		}
+		handover.Boards = append(handover.Boards, HandoverBoard{
+			BoardID:   board.ID,
+			BoardName: board.BoardName,
+		})
+	}
+
+	for _, assignment := range assignments {
+		//the successor must be on the board of the task to work on it
+		if board, added, err := addSuccessorToTaskBoard(tx, assignment.TaskID, successor.ID); err != nil {
+			return handover, err
+		} else if added {
+			handover.Memberships = append(handover.Memberships, HandoverBoard{
+				BoardID:   board.ID,
+				BoardName: board.BoardName,
+			})
+		}
+
+		var count int64
+		tx.Model(&models.TaskAssignee{}).
+			Where("task_id = ? AND user_id = ?", assignment.TaskID, successor.ID).
+			Count(&count)
+
+		result := handoverReassigned
+		var err error
+		if count > 0 {
+			result = handoverMerged
+			err = tx.Delete(&assignment).Error
+		} else {
+			err = tx.Model(&assignment).Update("user_id", successor.ID).Error
+		}
+		if err != nil {
+			return handover, err
+		}
+		handover.Assignments = append(handover.Assignments, HandoverAssignment{
+			TaskAssigneeID: assignment.ID,
+			TaskID:         assignment.TaskID,
+			Result:         result,
+		})
+	}
+
+	return handover, nil
+}
+
+// makes the successor a preparer of the board of the task, unless they own
+// it or are already a member
+func addSuccessorToTaskBoard(tx *gorm.DB, taskID uint, successorID uint) (models.Board, bool, error) {
+	var board models.Board
+	err := tx.Joins("JOIN column_boards ON column_boards.board_id = boards.id").
+		Joins("JOIN tasks ON tasks.column_board_id = column_boards.id").
+		Where("tasks.id = ?", taskID).
+		First(&board).Error
+	if err != nil {
+		return board, false, err
+	}
+	if board.OwnerID == successorID {
+		return board, false, nil
+	}
+
+	var count int64
+	tx.Model(&models.BoardMember{}).
+		Where("board_id = ? AND user_id = ?", board.ID, successorID).
+		Count(&count)
+	if count > 0 {
+		return board, false, nil
+	}
+
+	member := models.BoardMember{
+		BoardID: board.ID,
+		UserID:  successorID,
+		Role:    permissions.RolePreparer,
+	}
+	return board, true, tx.Create(&member).Error
+}
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"gofiber/permissions"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDeactivateUserAddsSuccessorToBoards(t *testing.T) {
+	setupTestDB(t)
+	app := fiber.New()
+	app.Post("/api/admin/users/:id/deactivate", middleware.Protected(), middleware.SessionOnly(), middleware.AdminOnly(), DeactivateUser)
+
+	admin := createTestUser(t, "admin")
+	database.DB.Model(&admin).Update("is_admin", true)
+	leaver := createTestUser(t, "leaver")
+	successor := createTestUser(t, "successor")
+
+	//a board of someone else where the leaver works, and one the leaver owns
+	shared := models.Board{BoardName: "Shared", OwnerID: admin.ID}
+	owned := models.Board{BoardName: "Owned", OwnerID: leaver.ID}
+	for _, board := range []*models.Board{&shared, &owned} {
+		database.DB.Create(board)
+		column := models.ColumnBoard{BoardID: board.ID, ColumnName: "To Do"}
+		database.DB.Create(&column)
+		task := models.Task{ColumnBoardID: column.ID, Title: "Task"}
+		database.DB.Create(&task)
+		database.DB.Create(&models.TaskAssignee{TaskID: task.ID, UserID: leaver.ID, AssignedByUserID: admin.ID})
+	}
+	database.DB.Create(&models.BoardMember{BoardID: shared.ID, UserID: leaver.ID, Role: permissions.RoleReviewer})
+
+	path := fmt.Sprintf("/api/admin/users/%d/deactivate", leaver.ID)
+	resp := sendJSON(t, app, "POST", path, sessionToken(t, admin), fiber.Map{"successor_user_id": successor.ID})
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var handover Handover
+	if err := json.NewDecoder(resp.Body).Decode(&handover); err != nil {
+		t.Fatal(err)
+	}
+	if len(handover.Assignments) != 2 {
+		t.Errorf("assignments = %+v, want 2", handover.Assignments)
+	}
+	if len(handover.Memberships) != 1 || handover.Memberships[0].BoardID != shared.ID {
+		t.Errorf("memberships = %+v, want the shared board only", handover.Memberships)
+	}
+
+	var member models.BoardMember
+	database.DB.Where("board_id = ? AND user_id = ?", shared.ID, successor.ID).First(&member)
+	if member.Role != permissions.RolePreparer {
+		t.Errorf("successor role = %q, want %q", member.Role, permissions.RolePreparer)
+	}
+	database.DB.First(&owned, owned.ID)
+	if owned.OwnerID != successor.ID {
+		t.Errorf("owner = %d, want %d", owned.OwnerID, successor.ID)
+	}
+}
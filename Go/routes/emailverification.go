@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"fmt"
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/mailer"
+	"gofiber/middleware"
+	"gofiber/models"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const emailVerificationPurpose = "verify-email"
+
+// sends a signed verification link for the current email of the user. the
+// link carries the email too, so it stops working if the email changes.
+func sendVerificationEmail(user *models.User) error {
+	expiresAt := time.Now().Add(config.App.EmailVerificationTTL)
+	subject := fmt.Sprintf("%d:%s", user.ID, user.Email)
+	token := auth.Sign(config.App.SecretKey, emailVerificationPurpose, subject, expiresAt)
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.App.AppBaseURL, url.QueryEscape(token))
+	err := mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address with the link below. It expires in %s.\n\n%s\n",
+			user.Username, config.App.EmailVerificationTTL, link),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.VerificationSentAt = &now
+	return database.DB.Model(user).Update("verification_sent_at", now).Error
+}
+
+// POST verify email
+func VerifyEmail(c *fiber.Ctx) error {
+	type VerifyEmail struct {
+		Token string `json:"token"`
+	}
+
+	var verifyInput VerifyEmail
+
+	//parsing validation
+	if err := c.BodyParser(&verifyInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//signature validation
+	subject, err := auth.Verify(config.App.SecretKey, emailVerificationPurpose, verifyInput.Token)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	userID, email, _ := strings.Cut(subject, ":")
+
+	//the link is only valid for the email it was sent to
+	var user models.User
+	result := database.DB.
+		Where("id = ? AND email = ?", userID, email).
+		First(&user)
+	if result.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": auth.ErrInvalidSignature.Error(),
+			"data":    nil,
+		})
+	}
+
+	if user.EmailVerifiedAt == nil {
+		database.DB.Model(&user).Update("email_verified_at", time.Now())
+	}
+
+	return c.Status(200).SendString("Successfully Verified Email")
+}
+
+// POST resend verification email
+func ResendVerificationEmail(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	if user.EmailVerifiedAt != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Email is already verified",
+			"data":    nil,
+		})
+	}
+
+	//rate limiting
+	if user.VerificationSentAt != nil {
+		retryAt := user.VerificationSentAt.Add(config.App.EmailVerificationCooldown)
+		if wait := time.Until(retryAt); wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return c.Status(429).JSON(fiber.Map{
+				"error":   true,
+				"message": "A verification email was sent recently, try again later",
+				"data": fiber.Map{
+					"retry_at": retryAt,
+				},
+			})
+		}
+	}
+
+	if err := sendVerificationEmail(&user); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not send verification email",
+			"data":    nil,
+		})
+	}
+
+	return c.Status(200).SendString("Successfully Sent Verification Email")
+}
+
+// response for a user that has to confirm their email first, board members
+// and task assignees must be verified
+func unverifiedEmailError(c *fiber.Ctx, user models.User) error {
+	return c.Status(400).JSON(fiber.Map{
+		"error":   true,
+		"message": "User " + user.Username + " has not verified their email address",
+		"data":    nil,
+	})
+}
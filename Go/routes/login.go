@@ -1,8 +1,13 @@
 package routes
 
 import (
+	"gofiber/auth"
+	"gofiber/config"
 	"gofiber/database"
+	"gofiber/middleware"
 	"gofiber/models"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -10,19 +15,35 @@ import (
 
 type Login struct {
 	gorm.Model
-	User   User
-	UserID uint `json:"user_id"`
+	User             User
+	UserID           uint      `json:"user_id"`
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	CSRFToken        string    `json:"csrf_token,omitempty"`
 }
 
-func CreateResponseLogin(login models.Login) Login {
+func CreateResponseLogin(login models.Login, accessToken string, refreshToken string) Login {
 	return Login{
-		UserID: login.UserID,
+		UserID:           login.UserID,
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        login.ExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: login.RefreshExpiresAt,
 	}
 }
 
-// GET
+// POST
 func CreateLogin(c *fiber.Ctx) error {
-	var userInput models.User
+	type CreateLogin struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	var userInput CreateLogin
 
 	//parsing validation
 	if err := c.BodyParser(&userInput); err != nil {
@@ -34,16 +55,6 @@ func CreateLogin(c *fiber.Ctx) error {
 	}
 
 	//input validation
-	result := database.DB.
-		Where("username = ? AND password = ?", userInput.Username, userInput.Password).
-		First(&userInput)
-	if result.RowsAffected == 0 {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Incorrect username or password",
-		})
-	}
-
 	if userInput.Username == "" && userInput.Password == "" {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
@@ -64,12 +75,142 @@ func CreateLogin(c *fiber.Ctx) error {
 		})
 	}
 
+	//brute-force protection, the attempt counts as failed until the password
+	//is checked
+	if lockout, locked := activeLoginLockout(userInput.Username, c.IP()); locked {
+		recordLoginAttempt(c, userInput.Username, nil, models.LoginBlocked)
+		return tooManyLoginAttempts(c, lockout.LockedUntil)
+	}
+	attempt := startLoginAttempt(c, userInput.Username)
+	if wait := loginDelay(userInput.Username, attempt.ID); wait > 0 {
+		finishLoginAttempt(&attempt, nil, models.LoginBlocked)
+		return tooManyLoginAttempts(c, time.Now().Add(wait))
+	}
+
+	//credential validation
+	var user models.User
+	result := database.DB.
+		Where("username = ?", userInput.Username).
+		First(&user)
+	if result.RowsAffected == 0 {
+		auth.SimulatePasswordCheck(userInput.Password)
+		lockLoginSubjects(c, userInput.Username, nil)
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Incorrect username or password",
+		})
+	}
+
+	ok, needsRehash := auth.CheckPassword(user.Password, userInput.Password)
+	if !ok {
+		finishLoginAttempt(&attempt, &user.ID, models.LoginFailed)
+		lockLoginSubjects(c, userInput.Username, &user.ID)
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Incorrect username or password",
+		})
+	}
+
+	//deactivated accounts can not log in
+	if user.DeactivatedAt != nil {
+		finishLoginAttempt(&attempt, &user.ID, models.LoginBlocked)
+		return accountDeactivated(c)
+	}
+
+	//rehash legacy plaintext password
+	if needsRehash {
+		if hashedPassword, err := auth.HashPassword(userInput.Password); err == nil {
+			database.DB.Model(&user).Update("password", hashedPassword)
+		}
+	}
+
+	//second factor is required before a session is issued
+	if hasTwoFactor(user.ID) {
+		finishLoginAttempt(&attempt, &user.ID, models.LoginChallenged)
+		return createLoginChallenge(c, user)
+	}
+
+	finishLoginAttempt(&attempt, &user.ID, models.LoginSucceeded)
+	return createSession(c, user)
+}
+
+// creates a session for an authenticated user and writes the tokens
+func createSession(c *fiber.Ctx, user models.User) error {
+	if user.DeactivatedAt != nil {
+		return accountDeactivated(c)
+	}
+
 	login := models.Login{
-		UserID: userInput.ID,
+		UserID:           user.ID,
+		RefreshExpiresAt: time.Now().Add(auth.RefreshTokenTTL),
+		ClientIP:         c.IP(),
+		UserAgent:        truncate(c.Get(fiber.HeaderUserAgent), 255),
+	}
+	accessToken, refreshToken, err := rotateLoginTokens(&login)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not issue access token",
+			"data":    err.Error(),
+		})
 	}
 
 	//insert database
-	database.DB.Create(&login)
-	responseLogin := CreateResponseLogin(login)
+	if err := database.DB.Create(&login).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not create login",
+			"data":    err.Error(),
+		})
+	}
+	responseLogin := CreateResponseLogin(login, accessToken, refreshToken)
+	responseLogin.CSRFToken = setSessionCookie(c, login, accessToken)
 	return c.Status(200).JSON(responseLogin)
 }
+
+// sets the access token in the session cookie for browser sessions and
+// returns the CSRF token the frontend must send with it
+func setSessionCookie(c *fiber.Ctx, login models.Login, accessToken string) string {
+	cookie := middleware.SessionCookie(login, accessToken)
+	if cookie == nil {
+		return ""
+	}
+	c.Cookie(cookie)
+	return auth.CSRFToken(config.App.SecretKey, login.TokenHash)
+}
+
+// sets fresh access and refresh token hashes on the session and returns the
+// plain tokens for the client. the session expiry itself is not extended.
+func rotateLoginTokens(login *models.Login) (string, string, error) {
+	accessToken, accessHash, err := auth.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, refreshHash, err := auth.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	login.TokenHash = accessHash
+	login.ExpiresAt = time.Now().Add(auth.AccessTokenTTL)
+	if login.ExpiresAt.After(login.RefreshExpiresAt) {
+		login.ExpiresAt = login.RefreshExpiresAt
+	}
+	login.RefreshTokenHash = refreshHash
+	return accessToken, refreshToken, nil
+}
+
+func truncate(value string, size int) string {
+	if len(value) > size {
+		return strings.ToValidUTF8(value[:size], "")
+	}
+	return value
+}
+
+func accountDeactivated(c *fiber.Ctx) error {
+	return c.Status(403).JSON(fiber.Map{
+		"error":   true,
+		"message": "This account is deactivated",
+		"data":    nil,
+	})
+}
@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// an app with the login endpoint and a user of every username, the password
+// is the username
+func setupLogin(t *testing.T, usernames ...string) *fiber.App {
+	t.Helper()
+
+	setupTestDB(t)
+	config.App.LoginMaxFailures = 100
+	config.App.LoginMaxFailuresPerIP = 100
+	config.App.LoginFailureWindow = time.Hour
+	config.App.LoginLockoutDuration = time.Hour
+	config.App.LoginBaseDelay = 0
+	config.App.LoginMaxDelay = 0
+
+	for _, username := range usernames {
+		user := createTestUser(t, username)
+		hashedPassword, err := auth.HashPassword(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		database.DB.Model(&user).Update("password", hashedPassword)
+	}
+
+	app := fiber.New()
+	app.Post("/api/login", CreateLogin)
+	return app
+}
+
+func login(t *testing.T, app *fiber.App, username string, password string) int {
+	t.Helper()
+	return sendJSON(t, app, "POST", "/api/login", "", fiber.Map{"username": username, "password": password}).StatusCode
+}
+
+func TestLoginLocksClientIP(t *testing.T) {
+	app := setupLogin(t, "attacker", "alice", "bob")
+	config.App.LoginMaxFailuresPerIP = 3
+
+	//logging in to a known account in between does not reset the count of
+	//the client ip
+	for i, victim := range []string{"alice", "bob", "alice"} {
+		if status := login(t, app, victim, "guess"); status != 400 {
+			t.Fatalf("login(%s) = %d, want 400", victim, status)
+		}
+		if i == 2 {
+			break
+		}
+		if status := login(t, app, "attacker", "attacker"); status != 200 {
+			t.Fatalf("login(attacker) = %d, want 200", status)
+		}
+	}
+	if status := login(t, app, "attacker", "attacker"); status != 429 {
+		t.Errorf("login after the ip limit = %d, want 429", status)
+	}
+}
+
+func TestLoginSuccessResetsAccountFailures(t *testing.T) {
+	app := setupLogin(t, "alice")
+	config.App.LoginMaxFailures = 3
+
+	for i := 0; i < 4; i++ {
+		login(t, app, "alice", "guess")
+		if status := login(t, app, "alice", "alice"); status != 200 {
+			t.Fatalf("login %d = %d, want 200", i, status)
+		}
+	}
+}
+
+func TestLoginDelayAppliesToParallelAttempts(t *testing.T) {
+	app := setupLogin(t, "alice")
+	config.App.LoginBaseDelay = time.Minute
+	config.App.LoginMaxDelay = time.Hour
+
+	statuses := make([]int, 5)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = login(t, app, "alice", "guess")
+		}()
+	}
+	wg.Wait()
+
+	//only the first attempt is checked, the others have to wait for it
+	checked := 0
+	for _, status := range statuses {
+		switch status {
+		case 400:
+			checked++
+		case 429:
+		default:
+			t.Errorf("status = %d, want 400 or 429", status)
+		}
+	}
+	if checked != 1 {
+		t.Errorf("%d attempts were checked, want 1 (statuses %v)", checked, statuses)
+	}
+}
@@ -0,0 +1,278 @@
+package routes
+
+import (
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type LoginAttempt struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent"`
+	Result    string    `json:"result"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func createResponseLoginAttempt(attempt models.LoginAttempt) LoginAttempt {
+	return LoginAttempt{
+		ID:        attempt.ID,
+		Username:  attempt.Username,
+		ClientIP:  attempt.ClientIP,
+		UserAgent: attempt.UserAgent,
+		Result:    attempt.Result,
+		CreatedAt: attempt.CreatedAt,
+	}
+}
+
+type LoginLockout struct {
+	ID          uint      `json:"id"`
+	Scope       string    `json:"scope"`
+	Subject     string    `json:"subject"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func createResponseLoginLockout(lockout models.LoginLockout) LoginLockout {
+	return LoginLockout{
+		ID:          lockout.ID,
+		Scope:       lockout.Scope,
+		Subject:     lockout.Subject,
+		LockedUntil: lockout.LockedUntil,
+		CreatedAt:   lockout.CreatedAt,
+	}
+}
+
+// GET login history of the current user
+func GetLoginAttempts(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+	attempts := []models.LoginAttempt{}
+
+	//read database
+	database.DB.
+		Where("user_id = ? OR username = ?", user.ID, user.Username).
+		Order("created_at desc").
+		Limit(100).
+		Find(&attempts)
+	responseAttempts := []LoginAttempt{}
+
+	for _, attempt := range attempts {
+		responseAttempt := createResponseLoginAttempt(attempt)
+		responseAttempts = append(responseAttempts, responseAttempt)
+	}
+	return c.Status(200).JSON(responseAttempts)
+}
+
+// GET All active LoginLockout
+func GetLoginLockouts(c *fiber.Ctx) error {
+	lockouts := []models.LoginLockout{}
+
+	//read database
+	database.DB.
+		Where("locked_until > ? AND unlocked_at IS NULL", time.Now()).
+		Order("created_at desc").
+		Find(&lockouts)
+	responseLockouts := []LoginLockout{}
+
+	for _, lockout := range lockouts {
+		responseLockout := createResponseLoginLockout(lockout)
+		responseLockouts = append(responseLockouts, responseLockout)
+	}
+	return c.Status(200).JSON(responseLockouts)
+}
+
+// POST unlock
+func UnlockLoginLockout(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	admin := middleware.CurrentUser(c)
+
+	//check lockout id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find active LoginLockout
+	var lockout models.LoginLockout
+	result := database.DB.
+		Where("id = ? AND locked_until > ? AND unlocked_at IS NULL", id, time.Now()).
+		First(&lockout)
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": "Lockout not found",
+		})
+	}
+
+	now := time.Now()
+	lockout.UnlockedAt = &now
+	lockout.UnlockedByUserID = &admin.ID
+
+	//update database
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&lockout).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUnlockLoginLockout, models.AuditTargetLockout, lockout.ID, fiber.Map{
+			"scope":   lockout.Scope,
+			"subject": lockout.Subject,
+		})
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).SendString("Successfully Unlocked")
+}
+
+// returns the active lockout of the username or the client ip, if any
+func activeLoginLockout(username string, ip string) (models.LoginLockout, bool) {
+	var lockout models.LoginLockout
+	result := database.DB.
+		Where("((scope = ? AND subject = ?) OR (scope = ? AND subject = ?)) AND locked_until > ? AND unlocked_at IS NULL",
+			models.LockoutAccount, truncate(username, 50), models.LockoutIP, ip, time.Now()).
+		Order("locked_until desc").
+		First(&lockout)
+	return lockout, result.RowsAffected > 0
+}
+
+// counts the failed attempts of a username or client ip since the failure
+// window started or the last lockout, whichever is the most recent. for a
+// username the window also restarts with its last successful login, a client
+// ip can not reset its count by logging in to an account it knows. only the
+// attempts before the attempt with id before are counted, all when it is 0.
+// usernames are stored truncated, so is the subject.
+func recentLoginFailures(column string, scope string, subject string, before uint) (int64, time.Time) {
+	subject = truncate(subject, 50)
+	since := time.Now().Add(-config.App.LoginFailureWindow)
+
+	var lastSuccess models.LoginAttempt
+	if scope == models.LockoutAccount && database.DB.Where(column+" = ? AND result = ?", subject, models.LoginSucceeded).
+		Order("created_at desc").
+		First(&lastSuccess).RowsAffected > 0 && lastSuccess.CreatedAt.After(since) {
+		since = lastSuccess.CreatedAt
+	}
+
+	var lastLockout models.LoginLockout
+	if database.DB.Where("scope = ? AND subject = ?", scope, subject).
+		Order("created_at desc").
+		First(&lastLockout).RowsAffected > 0 && lastLockout.CreatedAt.After(since) {
+		since = lastLockout.CreatedAt
+	}
+
+	failures := database.DB.Model(&models.LoginAttempt{}).
+		Where(column+" = ? AND result = ? AND created_at > ?", subject, models.LoginFailed, since)
+	if before != 0 {
+		failures = failures.Where("id < ?", before)
+	}
+	failures = failures.Session(&gorm.Session{})
+
+	var count int64
+	failures.Count(&count)
+	if count == 0 {
+		return 0, time.Time{}
+	}
+
+	var lastFailure models.LoginAttempt
+	failures.Order("created_at desc").First(&lastFailure)
+	return count, lastFailure.CreatedAt
+}
+
+// how long the client has to wait before the attempt with id before for the
+// username, doubling with every failed attempt
+func loginDelay(username string, before uint) time.Duration {
+	count, lastFailure := recentLoginFailures("username", models.LockoutAccount, username, before)
+	if count == 0 {
+		return 0
+	}
+
+	delay := config.App.LoginMaxDelay
+	if count < 32 {
+		delay = time.Duration(math.Min(
+			float64(config.App.LoginBaseDelay)*math.Pow(2, float64(count-1)),
+			float64(config.App.LoginMaxDelay),
+		))
+	}
+	return time.Until(lastFailure.Add(delay))
+}
+
+// records an attempt as failed before the password is checked, so parallel
+// requests count each other and only the first one gets past loginDelay. the
+// result is set with finishLoginAttempt once it is known.
+func startLoginAttempt(c *fiber.Ctx, username string) models.LoginAttempt {
+	attempt := models.LoginAttempt{
+		Username:  truncate(username, 50),
+		ClientIP:  c.IP(),
+		UserAgent: truncate(c.Get(fiber.HeaderUserAgent), 255),
+		Result:    models.LoginFailed,
+	}
+	database.DB.Create(&attempt)
+	return attempt
+}
+
+func finishLoginAttempt(attempt *models.LoginAttempt, userID *uint, result string) {
+	attempt.UserID = userID
+	attempt.Result = result
+	database.DB.Model(attempt).Select("UserID", "Result").Updates(attempt)
+}
+
+// records a failed attempt and locks the username or the client ip once they
+// reach their threshold
+func registerLoginFailure(c *fiber.Ctx, username string, userID *uint) {
+	recordLoginAttempt(c, username, userID, models.LoginFailed)
+	lockLoginSubjects(c, username, userID)
+}
+
+// locks the username or the client ip of a failed attempt once they reach
+// their threshold
+func lockLoginSubjects(c *fiber.Ctx, username string, userID *uint) {
+	subjects := []struct {
+		column  string
+		scope   string
+		subject string
+		limit   int
+	}{
+		{"username", models.LockoutAccount, username, config.App.LoginMaxFailures},
+		{"client_ip", models.LockoutIP, c.IP(), config.App.LoginMaxFailuresPerIP},
+	}
+	for _, s := range subjects {
+		if count, _ := recentLoginFailures(s.column, s.scope, s.subject, 0); count < int64(s.limit) {
+			continue
+		}
+		database.DB.Create(&models.LoginLockout{
+			Scope:       s.scope,
+			Subject:     truncate(s.subject, 50),
+			LockedUntil: time.Now().Add(config.App.LoginLockoutDuration),
+		})
+		recordLoginAttempt(c, username, userID, models.LoginLocked)
+	}
+}
+
+func recordLoginAttempt(c *fiber.Ctx, username string, userID *uint, result string) {
+	database.DB.Create(&models.LoginAttempt{
+		UserID:    userID,
+		Username:  truncate(username, 50),
+		ClientIP:  c.IP(),
+		UserAgent: truncate(c.Get(fiber.HeaderUserAgent), 255),
+		Result:    result,
+	})
+}
+
+func tooManyLoginAttempts(c *fiber.Ctx, retryAt time.Time) error {
+	seconds := int(math.Ceil(time.Until(retryAt).Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(429).JSON(fiber.Map{
+		"error":   true,
+		"message": "Too many failed login attempts, try again later",
+		"data": fiber.Map{
+			"retry_at": retryAt,
+		},
+	})
+}
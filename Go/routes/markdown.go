@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"fmt"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/markdown"
+	"gofiber/models"
+	"slices"
+)
+
+// renders the descriptions of tasks, Markdown written on a board, to safe
+// HTML. #id references only link to tasks of the same board, other ids stay
+// plain text. the tasks referenced by all descriptions are looked up in one
+// query. the ColumnBoard of every task must be loaded.
+func renderDescriptions(tasks []models.Task) []string {
+	documents := make([]*markdown.Document, len(tasks))
+	ids := []uint{}
+	for i, task := range tasks {
+		if task.Description == "" {
+			continue
+		}
+		documents[i] = markdown.Parse(task.Description)
+		ids = append(ids, documents[i].References()...)
+	}
+
+	//board of every referenced task that still exists
+	boardOf := map[uint]uint{}
+	if len(ids) > 0 {
+		slices.Sort(ids)
+		type reference struct {
+			ID      uint
+			BoardID uint
+		}
+		references := []reference{}
+		database.DB.Model(&models.Task{}).
+			Select("tasks.id, column_boards.board_id").
+			Joins("JOIN column_boards ON column_boards.id = tasks.column_board_id AND column_boards.deleted_at IS NULL").
+			Where("tasks.id IN ?", slices.Compact(ids)).
+			Scan(&references)
+		for _, reference := range references {
+			boardOf[reference.ID] = reference.BoardID
+		}
+	}
+
+	descriptions := make([]string, len(tasks))
+	for i, document := range documents {
+		if document == nil {
+			continue
+		}
+		boardID := tasks[i].ColumnBoard.BoardID
+		descriptions[i] = document.HTML(func(id uint) string {
+			if referenced, ok := boardOf[id]; !ok || referenced != boardID {
+				return ""
+			}
+			return fmt.Sprintf("%s/tasks/%d", config.App.AppBaseURL, id)
+		})
+	}
+	return descriptions
+}
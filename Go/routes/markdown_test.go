@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"fmt"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestRenderDescriptions(t *testing.T) {
+	setupTestDB(t)
+	config.App.AppBaseURL = "https://kanban.test"
+
+	createColumn := func() models.ColumnBoard {
+		board := models.Board{BoardName: "Board"}
+		database.DB.Create(&board)
+		column := models.ColumnBoard{BoardID: board.ID, ColumnName: "To Do"}
+		database.DB.Create(&column)
+		return column
+	}
+	column, otherColumn := createColumn(), createColumn()
+	first := models.Task{ColumnBoardID: column.ID, Title: "First"}
+	second := models.Task{ColumnBoardID: column.ID, Title: "Second"}
+	other := models.Task{ColumnBoardID: otherColumn.ID, Title: "Other board"}
+	deleted := models.Task{ColumnBoardID: column.ID, Title: "Deleted"}
+	for _, task := range []*models.Task{&first, &second, &other, &deleted} {
+		if err := database.DB.Create(task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	database.DB.Delete(&deleted)
+
+	first.Description = fmt.Sprintf("#%d #%d #%d #999", second.ID, other.ID, deleted.ID)
+	second.Description = fmt.Sprintf("back to #%d", first.ID)
+	tasks := []models.Task{first, second, other}
+	for i := range tasks {
+		database.DB.First(&tasks[i].ColumnBoard, tasks[i].ColumnBoardID)
+	}
+
+	//all descriptions share one lookup of the referenced tasks
+	queries := 0
+	count := func(*gorm.DB) { queries++ }
+	database.DB.Callback().Query().Before("gorm:query").Register("test:count", count)
+	database.DB.Callback().Row().Before("gorm:row").Register("test:count", count)
+	descriptions := renderDescriptions(tasks)
+	if queries != 1 {
+		t.Errorf("renderDescriptions() ran %d queries, want 1", queries)
+	}
+
+	link := func(id uint) string {
+		return fmt.Sprintf(`<a href="https://kanban.test/tasks/%d" class="task-link" data-task-id="%d">#%d</a>`, id, id, id)
+	}
+	//tasks of other boards and deleted tasks are not linked
+	want := []string{
+		fmt.Sprintf("<p>%s #%d #%d #999</p>\n", link(second.ID), other.ID, deleted.ID),
+		fmt.Sprintf("<p>back to %s</p>\n", link(first.ID)),
+		"",
+	}
+	for i := range want {
+		if descriptions[i] != want[i] {
+			t.Errorf("descriptions[%d] = %q, want %q", i, descriptions[i], want[i])
+		}
+	}
+}
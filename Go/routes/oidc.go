@@ -0,0 +1,306 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/models"
+	"gofiber/oidc"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// time the user has to log in at the provider
+const oidcAuthRequestTTL = 10 * time.Minute
+
+// the state is also kept in this cookie, so the callback only completes in
+// the browser that started the login
+const oidcStateCookie = "oidc_state"
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// discovers the provider on first use, and again after a failure
+func getOIDCProvider(c *fiber.Ctx) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+	provider, err := oidc.Discover(c.Context(), config.App.OIDCIssuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = provider
+	return oidcProvider, nil
+}
+
+func oidcConfig() oidc.Config {
+	return oidc.Config{
+		ClientID:     config.App.OIDCClientID,
+		ClientSecret: config.App.OIDCClientSecret,
+		RedirectURL:  config.App.OIDCRedirectURL,
+		Scopes:       config.App.OIDCScopes,
+	}
+}
+
+// GET redirect to the identity provider
+func OIDCLogin(c *fiber.Ctx) error {
+	if config.App.OIDCIssuer == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": "Single sign-on is not configured",
+			"data":    nil,
+		})
+	}
+
+	provider, err := getOIDCProvider(c)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{
+			"error":   true,
+			"message": "Identity provider is not available",
+			"data":    err.Error(),
+		})
+	}
+
+	state, errState := oidc.RandomString()
+	nonce, errNonce := oidc.RandomString()
+	verifier, challenge, errPKCE := oidc.GeneratePKCE()
+	if err := errors.Join(errState, errNonce, errPKCE); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	authRequest := models.OIDCAuthRequest{
+		StateHash:    auth.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcAuthRequestTTL),
+	}
+
+	//insert database
+	if err := database.DB.Create(&authRequest).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	c.Cookie(oidcStateCookieFor(state, authRequest.ExpiresAt))
+	return c.Redirect(provider.AuthCodeURL(oidcConfig(), state, nonce, challenge), 302)
+}
+
+// GET callback from the identity provider
+func OIDCCallback(c *fiber.Ctx) error {
+	if config.App.OIDCIssuer == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": "Single sign-on is not configured",
+			"data":    nil,
+		})
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Single sign-on was cancelled or failed",
+			"data":    errorCode,
+		})
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Missing code or state",
+			"data":    nil,
+		})
+	}
+
+	//the state must come from the browser that started the login
+	browserState := c.Cookies(oidcStateCookie)
+	c.Cookie(oidcStateCookieFor("", time.Unix(0, 0)))
+	if subtle.ConstantTimeCompare([]byte(browserState), []byte(state)) != 1 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired login state",
+			"data":    nil,
+		})
+	}
+
+	//query to find the pending request, it can only be used once
+	var authRequest models.OIDCAuthRequest
+	result := database.DB.
+		Where("state_hash = ? AND expires_at > ? AND used_at IS NULL", auth.HashToken(state), time.Now()).
+		First(&authRequest)
+	if result.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired login state",
+			"data":    nil,
+		})
+	}
+	used := database.DB.Model(&models.OIDCAuthRequest{}).
+		Where("id = ? AND used_at IS NULL", authRequest.ID).
+		Update("used_at", time.Now())
+	if used.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired login state",
+			"data":    nil,
+		})
+	}
+
+	provider, err := getOIDCProvider(c)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{
+			"error":   true,
+			"message": "Identity provider is not available",
+			"data":    err.Error(),
+		})
+	}
+
+	rawIDToken, err := provider.Exchange(c.Context(), oidcConfig(), code, authRequest.CodeVerifier)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not complete single sign-on",
+			"data":    err.Error(),
+		})
+	}
+
+	claims, err := provider.VerifyIDToken(c.Context(), config.App.OIDCClientID, rawIDToken, authRequest.Nonce)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID token",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := findOrProvisionOIDCUser(claims)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	if user.DeactivatedAt != nil {
+		recordLoginAttempt(c, user.Username, &user.ID, models.LoginBlocked)
+		return accountDeactivated(c)
+	}
+
+	if hasTwoFactor(user.ID) {
+		return createLoginChallenge(c, user)
+	}
+
+	recordLoginAttempt(c, user.Username, &user.ID, models.LoginSucceeded)
+	return createSession(c, user)
+}
+
+// returns the user linked to the provider account. an unlinked account is
+// linked to the user with the same email if that user verified it, or a new
+// user is created. an unverified local account may have been registered by
+// someone else to take over the provider account, so it is never linked.
+func findOrProvisionOIDCUser(claims *oidc.Claims) (models.User, error) {
+	var user models.User
+
+	var identity models.ExternalIdentity
+	result := database.DB.
+		Where("issuer = ? AND subject = ?", claims.Issuer, claims.Subject).
+		First(&identity)
+	if result.RowsAffected > 0 {
+		if err := database.DB.First(&user, identity.UserID).Error; err != nil {
+			return user, errors.New("Linked account no longer exists")
+		}
+		return user, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return user, errors.New("The identity provider did not return a verified email")
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		users := []models.User{}
+		tx.Where("email = ?", claims.Email).Limit(2).Find(&users)
+		if len(users) > 1 {
+			return errors.New("Several accounts use this email, ask an administrator to link your account")
+		}
+
+		now := time.Now()
+		if len(users) == 1 {
+			user = users[0]
+			if user.EmailVerifiedAt == nil {
+				return errors.New("An account with this email exists but its email is not verified, log in with your password and verify it first")
+			}
+		} else {
+			//provision a new account without a password
+			user = models.User{
+				Username:        uniqueUsername(tx, claims),
+				Email:           claims.Email,
+				EmailVerifiedAt: &now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.ExternalIdentity{
+			UserID:  user.ID,
+			Issuer:  claims.Issuer,
+			Subject: claims.Subject,
+		}).Error
+	})
+	return user, err
+}
+
+// derives a free username from the preferred username or the email
+func uniqueUsername(tx *gorm.DB, claims *oidc.Claims) string {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		tx.Model(&models.User{}).Unscoped().Where("username = ?", username).Count(&count)
+		if count == 0 {
+			return username
+		}
+		username = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// the cookie is sent back on the redirect from the provider, which is a top
+// level navigation from another site, so it must not be SameSite=Strict
+func oidcStateCookieFor(state string, expires time.Time) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc",
+		Expires:  expires,
+		Secure:   config.App.CookieSecure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+}
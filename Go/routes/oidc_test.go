@@ -0,0 +1,262 @@
+package routes
+
+import (
+	"encoding/json"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/models"
+	"gofiber/oidc/oidctest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// starts a mock provider and an app with the single sign-on routes
+func setupOIDC(t *testing.T) (*fiber.App, *oidctest.Provider) {
+	t.Helper()
+	setupTestDB(t)
+
+	mock := oidctest.NewProvider(t, "kanban")
+	config.App.OIDCIssuer = mock.URL
+	config.App.OIDCClientID = "kanban"
+	config.App.OIDCRedirectURL = "http://localhost:8000/api/oidc/callback"
+	config.App.OIDCScopes = []string{"openid", "email", "profile"}
+
+	oidcProvider = nil
+	t.Cleanup(func() { oidcProvider = nil })
+
+	app := fiber.New()
+	app.Get("/api/oidc/login", OIDCLogin)
+	app.Get("/api/oidc/callback", OIDCCallback)
+	return app, mock
+}
+
+// starts a login and returns the redirect to the provider and the state
+// cookie set in the browser
+func startOIDCLogin(t *testing.T, app *fiber.App) (string, *http.Cookie) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/oidc/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 302 {
+		t.Fatalf("login status = %d", resp.StatusCode)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return resp.Header.Get("Location"), cookie
+		}
+	}
+	t.Fatal("login did not set the state cookie")
+	return "", nil
+}
+
+func oidcCallback(t *testing.T, app *fiber.App, code string, state string, cookie *http.Cookie) *http.Response {
+	t.Helper()
+
+	query := url.Values{"code": {code}, "state": {state}}
+	req := httptest.NewRequest("GET", "/api/oidc/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// runs a whole login for the provider account with claims
+func loginWithOIDC(t *testing.T, app *fiber.App, mock *oidctest.Provider, claims map[string]any) *http.Response {
+	t.Helper()
+
+	authURL, cookie := startOIDCLogin(t, app)
+	code, state, err := mock.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return oidcCallback(t, app, code, state, cookie)
+}
+
+func loggedInUserID(t *testing.T, resp *http.Response) uint {
+	t.Helper()
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("callback status = %d", resp.StatusCode)
+	}
+	var login Login
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		t.Fatal(err)
+	}
+	if login.AccessToken == "" || login.UserID == 0 {
+		t.Fatalf("callback did not create a session: %+v", login)
+	}
+	return login.UserID
+}
+
+func aliceClaims() map[string]any {
+	return map[string]any{
+		"sub":                "alice-subject",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+}
+
+func TestOIDCProvisionsNewUser(t *testing.T) {
+	app, mock := setupOIDC(t)
+
+	userID := loggedInUserID(t, loginWithOIDC(t, app, mock, aliceClaims()))
+
+	var user models.User
+	database.DB.First(&user, userID)
+	if user.Username != "alice" || user.Email != "alice@example.com" || user.EmailVerifiedAt == nil {
+		t.Errorf("provisioned user = %+v", user)
+	}
+
+	//the next login uses the linked account
+	if again := loggedInUserID(t, loginWithOIDC(t, app, mock, aliceClaims())); again != userID {
+		t.Errorf("second login as user %d, want %d", again, userID)
+	}
+	var count int64
+	database.DB.Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d users, want 1", count)
+	}
+}
+
+func TestOIDCAccountLinking(t *testing.T) {
+	verifiedAt := time.Now()
+	tests := []struct {
+		name       string
+		local      *models.User
+		claims     map[string]any
+		wantStatus int
+		wantLinked bool
+	}{
+		{
+			name:       "verified local email",
+			local:      &models.User{Username: "alice", Email: "alice@example.com", EmailVerifiedAt: &verifiedAt},
+			claims:     aliceClaims(),
+			wantStatus: 200,
+			wantLinked: true,
+		},
+		{
+			name:       "unverified local email",
+			local:      &models.User{Username: "alice", Email: "alice@example.com"},
+			claims:     aliceClaims(),
+			wantStatus: 403,
+		},
+		{
+			name:  "unverified provider email",
+			local: &models.User{Username: "alice", Email: "alice@example.com", EmailVerifiedAt: &verifiedAt},
+			claims: map[string]any{
+				"sub":            "alice-subject",
+				"email":          "alice@example.com",
+				"email_verified": false,
+			},
+			wantStatus: 403,
+		},
+		{
+			name:       "no email",
+			claims:     map[string]any{"sub": "alice-subject"},
+			wantStatus: 403,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := setupOIDC(t)
+			if tt.local != nil {
+				if err := database.DB.Create(tt.local).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp := loginWithOIDC(t, app, mock, tt.claims)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("callback status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			var identities []models.ExternalIdentity
+			database.DB.Find(&identities)
+			if !tt.wantLinked {
+				if len(identities) != 0 {
+					t.Errorf("provider account linked to user %d", identities[0].UserID)
+				}
+				if tt.local != nil {
+					var user models.User
+					database.DB.First(&user, tt.local.ID)
+					if (user.EmailVerifiedAt == nil) != (tt.local.EmailVerifiedAt == nil) {
+						t.Error("email verification of the local account changed")
+					}
+				}
+				return
+			}
+			if len(identities) != 1 || identities[0].UserID != tt.local.ID {
+				t.Errorf("identities = %+v, want one linked to user %d", identities, tt.local.ID)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	app, mock := setupOIDC(t)
+
+	authURL, cookie := startOIDCLogin(t, app)
+	code, state, err := mock.Authorize(authURL, aliceClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherCookie := startOIDCLogin(t, app)
+
+	//the state is bound to the browser that started the login
+	if resp := oidcCallback(t, app, code, state, nil); resp.StatusCode != 400 {
+		t.Errorf("callback without state cookie = %d, want 400", resp.StatusCode)
+	}
+	if resp := oidcCallback(t, app, code, state, otherCookie); resp.StatusCode != 400 {
+		t.Errorf("callback with the cookie of another login = %d, want 400", resp.StatusCode)
+	}
+
+	loggedInUserID(t, oidcCallback(t, app, code, state, cookie))
+
+	//the state can only be used once, even with a new code
+	code, _, err = mock.Authorize(authURL, aliceClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := oidcCallback(t, app, code, state, cookie); resp.StatusCode != 400 {
+		t.Errorf("replayed state = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestOIDCCallbackChecksNonce(t *testing.T) {
+	app, mock := setupOIDC(t)
+
+	claims := aliceClaims()
+	claims["nonce"] = "nonce-of-another-login"
+	if resp := loginWithOIDC(t, app, mock, claims); resp.StatusCode != 401 {
+		t.Errorf("callback with another nonce = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestOIDCCallbackChecksPKCE(t *testing.T) {
+	app, mock := setupOIDC(t)
+
+	authURL, cookie := startOIDCLogin(t, app)
+	code, state, err := mock.Authorize(authURL, aliceClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a code intercepted for another login can not be redeemed with this
+	//login's verifier
+	database.DB.Model(&models.OIDCAuthRequest{}).Where("1 = 1").Update("code_verifier", "another-verifier")
+	if resp := oidcCallback(t, app, code, state, cookie); resp.StatusCode != 401 {
+		t.Errorf("callback with another verifier = %d, want 401", resp.StatusCode)
+	}
+}
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"fmt"
+	"gofiber/auth"
+	"gofiber/database"
+	"gofiber/models"
+	"gofiber/validation"
+
+	"gorm.io/gorm"
+)
+
+// checks a new password of user against the password policy and their last
+// passwords, and returns every rule that failed
+func checkNewPassword(user models.User, password string) error {
+	policy := validation.DefaultPasswordPolicy
+
+	errs := validation.Errors{}
+	if err := policy.Validate(password, user.Username, user.Email); err != nil {
+		errs = err.(validation.Errors)
+	}
+	if password != "" && user.ID != 0 && policy.History > 0 && passwordReused(user, password, policy.History) {
+		errs = append(errs, &validation.FieldError{
+			Field:   "password",
+			Code:    "reused",
+			Message: fmt.Sprintf("Password must not be one of your last %d passwords", policy.History),
+		})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func passwordReused(user models.User, password string, history int) bool {
+	previous := []models.PasswordHistory{}
+	database.DB.
+		Where("user_id = ?", user.ID).
+		Order("id desc").
+		Limit(history).
+		Find(&previous)
+
+	//accounts older than the history only have their current password
+	hashes := []string{user.Password}
+	for _, entry := range previous {
+		hashes = append(hashes, entry.PasswordHash)
+	}
+	for _, hash := range hashes {
+		if ok, _ := auth.CheckPassword(hash, password); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remembers a newly set password and forgets the ones beyond the history
+func recordPasswordHistory(tx *gorm.DB, userID uint, passwordHash string) error {
+	if err := tx.Create(&models.PasswordHistory{
+		UserID:       userID,
+		PasswordHash: passwordHash,
+	}).Error; err != nil {
+		return err
+	}
+
+	history := validation.DefaultPasswordPolicy.History
+	if history < 1 {
+		history = 1
+	}
+	var ids []uint
+	tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id desc").
+		Pluck("id", &ids)
+	if len(ids) <= history {
+		return nil
+	}
+	return tx.Unscoped().Delete(&models.PasswordHistory{}, ids[history:]).Error
+}
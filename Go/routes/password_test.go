@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"gofiber/auth"
+	"gofiber/database"
+	"gofiber/mailer"
+	"gofiber/middleware"
+	"gofiber/models"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TestUpdateOwnPassword(t *testing.T) {
+	setupTestDB(t)
+	app := fiber.New()
+	app.Put("/api/users/:id", middleware.Protected(), middleware.SessionOnly(), UpdateUser)
+
+	user := createTestUser(t, "alice")
+	hashedPassword, _ := auth.HashPassword("old password")
+	database.DB.Model(&user).Update("password", hashedPassword)
+	token, otherToken := sessionToken(t, user), sessionToken(t, user)
+	personalToken := models.PersonalAccessToken{UserID: user.ID, TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	database.DB.Create(&personalToken)
+	path := fmt.Sprintf("/api/users/%d", user.ID)
+
+	tests := []struct {
+		name            string
+		currentPassword string
+		want            int
+	}{
+		{"without current password", "", 400},
+		{"wrong current password", "guess", 400},
+		{"current password", "old password", 200},
+	}
+	for _, tt := range tests {
+		resp := sendJSON(t, app, "PUT", path, token, fiber.Map{"password": "new password", "current_password": tt.currentPassword})
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	database.DB.First(&user, user.ID)
+	if ok, _ := auth.CheckPassword(user.Password, "new password"); !ok {
+		t.Errorf("the password was not changed")
+	}
+
+	//only the session that changed the password stays valid
+	if resp := sendJSON(t, app, "PUT", path, token, fiber.Map{}); resp.StatusCode != 200 {
+		t.Errorf("current session: status = %d, want 200", resp.StatusCode)
+	}
+	if resp := sendJSON(t, app, "PUT", path, otherToken, fiber.Map{}); resp.StatusCode != 401 {
+		t.Errorf("other session: status = %d, want 401", resp.StatusCode)
+	}
+	database.DB.First(&personalToken, personalToken.ID)
+	if personalToken.RevokedAt == nil {
+		t.Errorf("the personal access token was not revoked")
+	}
+}
+
+func TestUpdateUserFailureKeepsPasswordHistory(t *testing.T) {
+	setupTestDB(t)
+	app := fiber.New()
+	app.Put("/api/users/:id", middleware.Protected(), middleware.SessionOnly(), UpdateUser)
+
+	user := createTestUser(t, "alice")
+	createTestUser(t, "bob")
+	hashedPassword, _ := auth.HashPassword("old password")
+	database.DB.Model(&user).Update("password", hashedPassword)
+	token, otherToken := sessionToken(t, user), sessionToken(t, user)
+	path := fmt.Sprintf("/api/users/%d", user.ID)
+	change := fiber.Map{"password": "new password", "current_password": "old password"}
+
+	historyCount := func() int64 {
+		var count int64
+		database.DB.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&count)
+		return count
+	}
+
+	resp := sendJSON(t, app, "PUT", path, token, fiber.Map{"username": "bob", "password": "new password", "current_password": "old password"})
+	if resp.StatusCode != 400 {
+		t.Errorf("duplicated username: status = %d, want 400", resp.StatusCode)
+	}
+
+	//the update of the user row fails
+	database.DB.Callback().Update().Before("gorm:update").Register("test:fail", func(tx *gorm.DB) {
+		tx.AddError(errors.New("update failed"))
+	})
+	resp = sendJSON(t, app, "PUT", path, token, change)
+	database.DB.Callback().Update().Remove("test:fail")
+	if resp.StatusCode != 500 {
+		t.Errorf("failed save: status = %d, want 500", resp.StatusCode)
+	}
+
+	if count := historyCount(); count != 0 {
+		t.Errorf("password history has %d entries, want 0", count)
+	}
+	database.DB.First(&user, user.ID)
+	if ok, _ := auth.CheckPassword(user.Password, "old password"); !ok {
+		t.Errorf("the password was changed")
+	}
+	if resp := sendJSON(t, app, "PUT", path, otherToken, fiber.Map{}); resp.StatusCode != 200 {
+		t.Errorf("other session: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// holds every message until it is released
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func (m blockingMailer) Send(message mailer.Message) error {
+	<-m.release
+	m.sent <- message
+	return nil
+}
+
+func TestForgotPasswordDoesNotWaitForTheMail(t *testing.T) {
+	setupTestDB(t)
+	app := fiber.New()
+	app.Post("/api/password/forgot", ForgotPassword)
+
+	previous := mailer.Default
+	slow := blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+	mailer.Default = slow
+	t.Cleanup(func() { mailer.Default = previous })
+
+	user := createTestUser(t, "alice")
+	for _, username := range []string{"nobody", "alice"} {
+		resp := sendJSON(t, app, "POST", "/api/password/forgot", "", fiber.Map{"username": username})
+		if resp.StatusCode != 200 {
+			t.Errorf("%s: status = %d, want 200", username, resp.StatusCode)
+		}
+	}
+
+	close(slow.release)
+	select {
+	case message := <-slow.sent:
+		if message.To != user.Email {
+			t.Errorf("mail sent to %q, want %q", message.To, user.Email)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no password reset mail was sent")
+	}
+}
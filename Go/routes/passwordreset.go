@@ -0,0 +1,219 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/mailer"
+	"gofiber/models"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errResetTokenUsed = errors.New("Reset token already used")
+
+// a new reset email is not sent while the previous one is younger than this
+const passwordResetCooldown = time.Minute
+
+// POST forgot password
+func ForgotPassword(c *fiber.Ctx) error {
+	type ForgotPassword struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+
+	var forgotInput ForgotPassword
+
+	//parsing validation
+	if err := c.BodyParser(&forgotInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//input validation
+	if forgotInput.Username == "" && forgotInput.Email == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Username or email is required",
+			"data":    nil,
+		})
+	}
+
+	users := []models.User{}
+	query := database.DB.Where("deactivated_at IS NULL")
+	if forgotInput.Username != "" {
+		query = query.Where("username = ?", forgotInput.Username)
+	} else {
+		query = query.Where("email = ?", forgotInput.Email)
+	}
+	query.Find(&users)
+
+	//the email is sent in the background so the response takes the same
+	//time whether the account exists or not. failures are only logged, they
+	//would reveal it as well.
+	for _, user := range users {
+		go func() {
+			if err := sendPasswordReset(user); err != nil {
+				log.Printf("password reset for user %d: %v", user.ID, err)
+			}
+		}()
+	}
+
+	//same answer whether the account exists or not
+	return c.Status(200).JSON(fiber.Map{
+		"error":   false,
+		"message": "If the account exists, a password reset link has been sent",
+		"data":    nil,
+	})
+}
+
+func sendPasswordReset(user models.User) error {
+	//throttle repeated requests for the same account
+	var count int64
+	database.DB.Model(&models.PasswordReset{}).
+		Where("user_id = ? AND used_at IS NULL AND created_at > ?", user.ID, time.Now().Add(-passwordResetCooldown)).
+		Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	token, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	reset := models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(config.App.PasswordResetTTL),
+	}
+	if err := database.DB.Create(&reset).Error; err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.App.AppBaseURL, url.QueryEscape(token))
+	return mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask for a password reset, you can ignore this email.\n",
+			user.Username, config.App.PasswordResetTTL, link),
+	})
+}
+
+// POST reset password
+func ResetPassword(c *fiber.Ctx) error {
+	type ResetPassword struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	var resetInput ResetPassword
+
+	//parsing validation
+	if err := c.BodyParser(&resetInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//input validation
+	if resetInput.Token == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Token is required",
+			"data":    nil,
+		})
+	} else if resetInput.Password == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Password is required",
+			"data":    nil,
+		})
+	}
+
+	//query to find unused PasswordReset
+	var reset models.PasswordReset
+	result := database.DB.
+		Where("token_hash = ? AND expires_at > ? AND used_at IS NULL", auth.HashToken(resetInput.Token), time.Now()).
+		First(&reset)
+	if result.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired reset token",
+			"data":    nil,
+		})
+	}
+
+	//password policy
+	var user models.User
+	if err := findUser(int(reset.UserID), &user); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired reset token",
+			"data":    nil,
+		})
+	}
+	if err := checkNewPassword(user, resetInput.Password); err != nil {
+		return validationError(c, err)
+	}
+
+	hashedPassword, err := auth.HashPassword(resetInput.Password)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	//set the password, burn every outstanding reset token, log out everywhere
+	//and revoke the personal access tokens
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		used := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if used.Error != nil {
+			return used.Error
+		}
+		if used.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", reset.UserID).
+			Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		if err := recordPasswordHistory(tx, reset.UserID, hashedPassword); err != nil {
+			return err
+		}
+		return revokeUserAccess(tx, reset.UserID, 0)
+	})
+	if err == errResetTokenUsed {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired reset token",
+			"data":    nil,
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).SendString("Successfully Reset Password")
+}
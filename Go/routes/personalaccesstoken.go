@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"gofiber/auth"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"gofiber/permissions"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// longest lifetime a personal access token can be created with
+const maxPersonalTokenTTL = 365 * 24 * time.Hour
+
+type PersonalAccessToken struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	ReadOnly   bool       `json:"read_only"`
+	BoardIDs   []uint     `json:"board_ids"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// token is only set right after creation, it can not be read again
+func createResponsePersonalAccessToken(personalToken models.PersonalAccessToken, token string) PersonalAccessToken {
+	boardIDs := []uint{}
+	for _, scope := range personalToken.Boards {
+		boardIDs = append(boardIDs, scope.BoardID)
+	}
+	return PersonalAccessToken{
+		ID:         personalToken.ID,
+		Name:       personalToken.Name,
+		Token:      token,
+		ReadOnly:   personalToken.ReadOnly,
+		BoardIDs:   boardIDs,
+		CreatedAt:  personalToken.CreatedAt,
+		ExpiresAt:  personalToken.ExpiresAt,
+		LastUsedAt: personalToken.LastUsedAt,
+	}
+}
+
+// POST
+func CreatePersonalAccessToken(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	type CreatePersonalAccessToken struct {
+		Name      string    `json:"name"`
+		ReadOnly  bool      `json:"read_only"`
+		BoardIDs  []uint    `json:"board_ids"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	var tokenInput CreatePersonalAccessToken
+
+	//parsing validation
+	if err := c.BodyParser(&tokenInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//input validation
+	if tokenInput.Name == "" || len(tokenInput.Name) > 50 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid name",
+			"data":    nil,
+		})
+	} else if !tokenInput.ExpiresAt.After(time.Now()) || tokenInput.ExpiresAt.After(time.Now().Add(maxPersonalTokenTTL)) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid expiry. Must be in the future and within 365 days",
+			"data":    nil,
+		})
+	}
+
+	//check the user can access every scoped board
+	scopes := []models.PersonalAccessTokenBoard{}
+	for _, boardID := range tokenInput.BoardIDs {
+		if err := authorizeBoard(c, boardID, permissions.ViewBoard); err != nil {
+			return authorizeError(c, err, permissions.ViewBoard)
+		}
+		scopes = append(scopes, models.PersonalAccessTokenBoard{BoardID: boardID})
+	}
+
+	token, tokenHash, err := auth.GeneratePersonalToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not issue access token",
+			"data":    err.Error(),
+		})
+	}
+
+	personalToken := models.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      tokenInput.Name,
+		TokenHash: tokenHash,
+		ReadOnly:  tokenInput.ReadOnly,
+		ExpiresAt: tokenInput.ExpiresAt,
+		Boards:    scopes,
+	}
+
+	//insert database
+	if err := database.DB.Create(&personalToken).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	responseToken := createResponsePersonalAccessToken(personalToken, token)
+	return c.Status(200).JSON(responseToken)
+}
+
+// GET All active PersonalAccessToken of the current user
+func GetPersonalAccessTokens(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+	personalTokens := []models.PersonalAccessToken{}
+
+	//read database
+	database.DB.
+		Preload("Boards").
+		Where("user_id = ? AND expires_at > ? AND revoked_at IS NULL", user.ID, time.Now()).
+		Find(&personalTokens)
+	responseTokens := []PersonalAccessToken{}
+
+	for _, personalToken := range personalTokens {
+		responseToken := createResponsePersonalAccessToken(personalToken, "")
+		responseTokens = append(responseTokens, responseToken)
+	}
+	return c.Status(200).JSON(responseTokens)
+}
+
+// DELETE
+func DeletePersonalAccessToken(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	user := middleware.CurrentUser(c)
+
+	//check token id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find PersonalAccessToken of the current user
+	var personalToken models.PersonalAccessToken
+	result := database.DB.
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+		First(&personalToken)
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": "Access token not found",
+		})
+	}
+
+	//revoke token
+	if err := database.DB.Model(&personalToken).Update("revoked_at", time.Now()).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).SendString("Successfully Revoked Access Token")
+}
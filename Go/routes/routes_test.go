@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// replaces database.DB with an empty in-memory database for the test and
+// restores the configuration afterwards
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every connection would open its own in-memory database
+	sqlDB.SetMaxOpenConns(1)
+
+	err = db.AutoMigrate(&models.User{}, &models.Login{}, &models.Board{}, &models.BoardMember{}, &models.ColumnBoard{}, &models.Task{}, &models.TaskAssignee{}, &models.LoginAttempt{}, &models.LoginLockout{}, &models.TwoFactor{}, &models.LoginChallenge{}, &models.ExternalIdentity{}, &models.OIDCAuthRequest{}, &models.AuditLog{}, &models.BoardInvitation{}, &models.PasswordReset{}, &models.PersonalAccessToken{}, &models.PasswordHistory{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousDB, previousConfig := database.DB, config.App
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previousDB
+		config.App = previousConfig
+		sqlDB.Close()
+	})
+}
+
+// creates an active user with a verified email
+func createTestUser(t *testing.T, username string) models.User {
+	t.Helper()
+
+	now := time.Now()
+	user := models.User{Username: username, Email: username + "@example.com", EmailVerifiedAt: &now}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// returns the access token of a new session of user
+func sessionToken(t *testing.T, user models.User) string {
+	t.Helper()
+
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, refreshHash, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := models.Login{
+		UserID:           user.ID,
+		TokenHash:        hash,
+		ExpiresAt:        time.Now().Add(auth.AccessTokenTTL),
+		RefreshTokenHash: refreshHash,
+		RefreshExpiresAt: time.Now().Add(auth.RefreshTokenTTL),
+	}
+	if err := database.DB.Create(&login).Error; err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// sends body as JSON to app with token as the bearer token
+func sendJSON(t *testing.T, app *fiber.App, method string, path string, token string, body any) *http.Response {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
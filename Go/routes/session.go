@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"gofiber/auth"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Session struct {
+	ID               uint      `json:"id"`
+	ClientIP         string    `json:"client_ip"`
+	UserAgent        string    `json:"user_agent"`
+	CreatedAt        time.Time `json:"created_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Current          bool      `json:"current"`
+	Impersonated     bool      `json:"impersonated"`
+}
+
+func createResponseSession(login models.Login, currentID uint) Session {
+	return Session{
+		ID:               login.ID,
+		ClientIP:         login.ClientIP,
+		UserAgent:        login.UserAgent,
+		CreatedAt:        login.CreatedAt,
+		RefreshExpiresAt: login.RefreshExpiresAt,
+		Current:          login.ID == currentID,
+		Impersonated:     login.ImpersonatorUserID != nil,
+	}
+}
+
+// POST refresh
+func RefreshLogin(c *fiber.Ctx) error {
+	type RefreshInput struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	var refreshInput RefreshInput
+
+	//parsing validation
+	if err := c.BodyParser(&refreshInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	if refreshInput.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Refresh token is required",
+			"data":    nil,
+		})
+	}
+
+	//query to find active session, impersonation sessions can not be refreshed
+	var login models.Login
+	result := database.DB.
+		Where("refresh_token_hash = ? AND refresh_expires_at > ? AND revoked_at IS NULL AND impersonator_user_id IS NULL",
+			auth.HashToken(refreshInput.RefreshToken), time.Now()).
+		First(&login)
+	if result.RowsAffected == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired refresh token",
+			"data":    nil,
+		})
+	}
+
+	//rotate both tokens, the old refresh token can not be used again
+	refreshHash := login.RefreshTokenHash
+	accessToken, refreshToken, err := rotateLoginTokens(&login)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not issue access token",
+			"data":    err.Error(),
+		})
+	}
+	login.ClientIP = c.IP()
+	login.UserAgent = truncate(c.Get(fiber.HeaderUserAgent), 255)
+
+	//update database only if no concurrent request used the refresh token first
+	result = database.DB.Model(&login).
+		Where("refresh_token_hash = ? AND revoked_at IS NULL", refreshHash).
+		Select("TokenHash", "ExpiresAt", "RefreshTokenHash", "ClientIP", "UserAgent").
+		Updates(&login)
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not refresh session",
+			"data":    result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired refresh token",
+			"data":    nil,
+		})
+	}
+
+	responseLogin := CreateResponseLogin(login, accessToken, refreshToken)
+	responseLogin.CSRFToken = setSessionCookie(c, login, accessToken)
+	return c.Status(200).JSON(responseLogin)
+}
+
+// POST logout
+func Logout(c *fiber.Ctx) error {
+	login := middleware.CurrentLogin(c)
+
+	//revoke current session
+	if err := revokeSessions(login.UserID, login.ID); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if cookie := middleware.SessionCookie(login, ""); cookie != nil {
+		cookie.Expires = time.Unix(0, 0)
+		c.Cookie(cookie)
+	}
+
+	return c.Status(200).SendString("Successfully Logged Out")
+}
+
+// GET All active Session of the current user
+func GetSessions(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+	current := middleware.CurrentLogin(c)
+	logins := []models.Login{}
+
+	//read database
+	database.DB.
+		Where("user_id = ? AND refresh_expires_at > ? AND revoked_at IS NULL", user.ID, time.Now()).
+		Order("created_at desc").
+		Find(&logins)
+	responseSessions := []Session{}
+
+	for _, login := range logins {
+		responseSession := createResponseSession(login, current.ID)
+		responseSessions = append(responseSessions, responseSession)
+	}
+	return c.Status(200).JSON(responseSessions)
+}
+
+// DELETE by ID
+func DeleteSession(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	user := middleware.CurrentUser(c)
+
+	//check session id
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	//query to find Session of the current user
+	var login models.Login
+	result := database.DB.
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+		First(&login)
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session not found",
+		})
+	}
+
+	//revoke session
+	if err := revokeSessions(user.ID, login.ID); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).SendString("Successfully Revoked Session")
+}
+
+// DELETE All Session of the current user
+func DeleteSessions(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	//revoke every session
+	if err := revokeSessions(user.ID, 0); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).SendString("Successfully Revoked All Sessions")
+}
+
+// revokes one session of a user, or all of them when loginID is 0
+func revokeSessions(userID uint, loginID uint) error {
+	query := database.DB.Model(&models.Login{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID)
+	if loginID != 0 {
+		query = query.Where("id = ?", loginID)
+	}
+	return query.Update("revoked_at", time.Now()).Error
+}
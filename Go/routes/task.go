@@ -4,11 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"gofiber/database"
+	"gofiber/middleware"
 	"gofiber/models"
+	"gofiber/permissions"
+	"gofiber/validation"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Task struct {
@@ -19,20 +24,52 @@ type Task struct {
 	DueDate        time.Time `json:"due_date"`
 	ColumnBoardID  uint      `json:"column_board_id"`
 	CreateByUserID uint      `json:"create_by_user_id"`
+	Rank           string    `json:"rank"`
+	Description    string    `json:"description"`
+	//the description rendered from Markdown, safe to insert into a page
+	DescriptionHTML string `json:"description_html"`
+	//name of the column the task is in, and whether it is a done column
+	Status string `json:"status"`
+	Done   bool   `json:"done"`
 }
 
+// the ColumnBoard of task must be loaded for the status and description
 func createResponseTask(task models.Task) Task {
-	return Task{
-		ColumnBoardID:  task.ColumnBoardID,
-		CreateByUserID: task.CreateByUserID,
-		Title:          task.Title,
-		DueDate:        task.DueDate,
+	return createResponseTasks([]models.Task{task})[0]
+}
+
+// renders the descriptions of all tasks at once, see renderDescriptions
+func createResponseTasks(tasks []models.Task) []Task {
+	descriptions := renderDescriptions(tasks)
+	responseTasks := []Task{}
+
+	for i, task := range tasks {
+		responseTasks = append(responseTasks, Task{
+			Model:           task.Model,
+			ColumnBoardID:   task.ColumnBoardID,
+			Rank:            task.Rank,
+			CreateByUserID:  task.CreateByUserID,
+			Title:           task.Title,
+			Description:     task.Description,
+			DescriptionHTML: descriptions[i],
+			DueDate:         task.DueDate,
+			Status:          task.ColumnBoard.ColumnName,
+			Done:            task.ColumnBoard.IsDone,
+		})
 	}
+	return responseTasks
 }
 
 // POST
 func CreateTask(c *fiber.Ctx) error {
-	var taskInput models.Task
+	type CreateTask struct {
+		Title         string    `json:"title"`
+		Description   string    `json:"description"`
+		DueDate       time.Time `json:"due_date"`
+		ColumnBoardID uint      `json:"column_board_id"`
+	}
+
+	var taskInput CreateTask
 
 	//parsing validation
 	if err := c.BodyParser(&taskInput); err != nil {
@@ -43,19 +80,17 @@ func CreateTask(c *fiber.Ctx) error {
 		})
 	}
 
+	//creator is the authenticated user
+	user := middleware.CurrentUser(c)
+	taskInput.Title = strings.TrimSpace(taskInput.Title)
+
 	//input validation
-	if taskInput.Title == "" && taskInput.DueDate.IsZero() && taskInput.ColumnBoardID == 0 && taskInput.CreateByUserID == 0 {
+	if taskInput.Title == "" && taskInput.DueDate.IsZero() && taskInput.ColumnBoardID == 0 {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
 			"message": "All field is required",
 			"data":    nil,
 		})
-	} else if taskInput.Title == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid title",
-			"data":    nil,
-		})
 	} else if taskInput.DueDate.IsZero() {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
@@ -68,56 +103,39 @@ func CreateTask(c *fiber.Ctx) error {
 			"message": "Invalid column board",
 			"data":    nil,
 		})
-	} else if taskInput.CreateByUserID == 0 {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid Create User ID",
-			"data":    nil,
-		})
 	}
-
-	//check if user exists
-	var user models.User
-	if err := database.DB.First(&user, taskInput.CreateByUserID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error":   true,
-			"message": "User not found",
-		})
+	if err := validateTask(taskInput.Title, taskInput.Description); err != nil {
+		return validationError(c, err)
 	}
 
-	//validate title input
-	validTitle := map[string]bool{
-		"New":         true,
-		"In Progress": true,
-		"Completed":   true,
-	}
-	if !validTitle[taskInput.Title] {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid role. Must be one of: New, In Progress, Completed",
-		})
-	}
-
-	//check title and ensure only one exists
-	result := database.DB.
-		Where("column_board_id = ? AND title = ?", taskInput.ColumnBoardID, taskInput.Title).
-		Find(&taskInput)
-	if result.RowsAffected > 0 {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "This task already exists in the column board",
-		})
+	//check board permission
+	if err := authorizeColumn(c, taskInput.ColumnBoardID, permissions.CreateTask); err != nil {
+		return authorizeError(c, err, permissions.CreateTask)
 	}
 
 	newTask := models.Task{
 		ColumnBoardID:  taskInput.ColumnBoardID,
-		CreateByUserID: taskInput.CreateByUserID,
+		CreateByUserID: user.ID,
 		Title:          taskInput.Title,
+		Description:    taskInput.Description,
 		DueDate:        taskInput.DueDate,
 	}
 
-	//insert database
-	database.DB.Create(&newTask)
+	//insert database, new tasks go to the end of the column
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockColumns(tx, newTask.ColumnBoardID); err != nil {
+			return err
+		}
+		var err error
+		if newTask.Rank, err = placeTask(tx, 0, newTask.ColumnBoardID, 0, 0); err != nil {
+			return err
+		}
+		return tx.Create(&newTask).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	database.DB.First(&newTask.ColumnBoard, newTask.ColumnBoardID)
 	responseTask := createResponseTask(newTask)
 	return c.Status(200).JSON(responseTask)
 }
@@ -126,19 +144,21 @@ func CreateTask(c *fiber.Ctx) error {
 func GetTasks(c *fiber.Ctx) error {
 	tasks := []models.Task{}
 
-	database.DB.Find(&tasks)
-	responseTasks := []Task{}
+	database.DB.
+		Where("column_board_id IN (?)", database.DB.Model(&models.ColumnBoard{}).
+			Select("id").
+			Where("board_id IN (?)", accessibleBoardIDs(c))).
+		Preload("ColumnBoard").
+		Order("column_board_id, rank_key, id").
+		Find(&tasks)
+	responseTasks := createResponseTasks(tasks)
 
-	for _, task := range tasks {
-		responseTask := createResponseTask(task)
-		responseTasks = append(responseTasks, responseTask)
-	}
 	return c.Status(200).JSON(responseTasks)
 }
 
 // query to find Task in DB
 func findTask(id int, task *models.Task) error {
-	database.DB.First(&task, "id=?", id)
+	database.DB.Preload("ColumnBoard").First(&task, "id=?", id)
 	if task.ID == 0 {
 		return errors.New("Task does not exist")
 	}
@@ -157,6 +177,12 @@ func GetTaskByID(c *fiber.Ctx) error {
 	if err := findTask(id, &task); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+
+	//check board permission
+	if err := authorizeColumn(c, task.ColumnBoardID, permissions.ViewTask); err != nil {
+		return authorizeError(c, err, permissions.ViewTask)
+	}
+
 	responseTask := createResponseTask(task)
 	return c.Status(200).JSON(responseTask)
 }
@@ -176,8 +202,14 @@ func UpdateTask(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeColumn(c, taskInput.ColumnBoardID, permissions.UpdateTask); err != nil {
+		return authorizeError(c, err, permissions.UpdateTask)
+	}
+
 	type UpdateTask struct {
-		Title string `json:"title"`
+		Title       string  `json:"title"`
+		Description *string `json:"description"`
 	}
 
 	var updateData UpdateTask
@@ -187,52 +219,150 @@ func UpdateTask(c *fiber.Ctx) error {
 		return c.Status(500).JSON(err.Error())
 	}
 
-	//check if task exists
-	if err := database.DB.First(&taskInput, taskInput.ID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
+	//null validation - if null, data is still the same
+	if title := strings.TrimSpace(updateData.Title); title != "" {
+		taskInput.Title = title
+	}
+	if updateData.Description != nil {
+		taskInput.Description = *updateData.Description
+	}
+	if err := validateTask(taskInput.Title, taskInput.Description); err != nil {
+		return validationError(c, err)
+	}
+
+	//update database
+	database.DB.Model(&taskInput).Select("Title", "Description").Updates(&taskInput)
+
+	responseTask := createResponseTask(taskInput)
+	return c.Status(200).JSON(responseTask)
+}
+
+// checks the title and description of a task and returns every rule that
+// failed
+func validateTask(title string, description string) error {
+	errs := validation.Errors{}
+	for _, err := range []error{
+		validation.ValidateTaskTitle(title),
+		validation.ValidateTaskDescription(description),
+	} {
+		if fieldErr, ok := err.(*validation.FieldError); ok {
+			errs = append(errs, fieldErr)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// POST move a task within its column or to another column of the board
+func MoveTask(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	var task models.Task
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that id is an integer")
+	}
+
+	if err := findTask(id, &task); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	//check board permission
+	if err := authorizeColumn(c, task.ColumnBoardID, permissions.UpdateTask); err != nil {
+		return authorizeError(c, err, permissions.UpdateTask)
+	}
+
+	type MoveTask struct {
+		ColumnBoardID uint `json:"column_board_id"`
+		AfterTaskID   uint `json:"after_task_id"`
+		BeforeTaskID  uint `json:"before_task_id"`
+	}
+
+	var moveInput MoveTask
+
+	//parsing validation
+	if err := c.BodyParser(&moveInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "User not found",
+			"message": "Invalid request body",
+			"data":    err.Error(),
 		})
 	}
 
-	//validate title input
-	validTitle := map[string]bool{
-		"New":         true,
-		"In Progress": true,
-		"Completed":   true,
+	//the target column defaults to the current one and must be on the same board
+	if moveInput.ColumnBoardID == 0 {
+		moveInput.ColumnBoardID = task.ColumnBoardID
+	}
+	if moveInput.AfterTaskID == task.ID || moveInput.BeforeTaskID == task.ID {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "A task can not be its own neighbor",
+			"data":    nil,
+		})
+	}
+	boardID, err := boardIDOfColumn(task.ColumnBoardID)
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	targetBoardID, err := boardIDOfColumn(moveInput.ColumnBoardID)
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
 	}
-	if updateData.Title != "" && !validTitle[updateData.Title] {
+	if targetBoardID != boardID {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid role. Must be one of: New, In Progress, Completed",
+			"message": "Tasks can only move between columns of the same board",
+			"data":    nil,
 		})
 	}
 
-	//duplicated validation
-	if updateData.Title != "" {
-		var existingTask models.Task
-		result := database.DB.
-			Where("column_board_id = ? AND title = ?", taskInput.ColumnBoardID, updateData.Title).
-			First(&existingTask)
+	//only the moved task gets a new rank, unless its column is rebalanced
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockColumns(tx, task.ColumnBoardID, moveInput.ColumnBoardID); err != nil {
+			return err
+		}
 
-		if result.RowsAffected > 0 {
-			return c.Status(400).JSON(fiber.Map{
-				"error":   true,
-				"message": "Duplicated task",
-			})
+		//read the task again, it can only leave its column while the column
+		//is locked, so it stays where it is until the end of tx
+		var current models.Task
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, task.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && current.ColumnBoardID != task.ColumnBoardID) {
+			return errTaskMoved
+		} else if err != nil {
+			return err
 		}
-		taskInput.Title = updateData.Title
-	}
+		task = current
 
-	//null validation - if null, data is still the same
-	if updateData.Title != "" {
-		taskInput.Title = updateData.Title
+		key, err := placeTask(tx, task.ID, moveInput.ColumnBoardID, moveInput.AfterTaskID, moveInput.BeforeTaskID)
+		if err != nil {
+			return err
+		}
+		task.ColumnBoardID = moveInput.ColumnBoardID
+		task.Rank = key
+		if err := tx.Model(&task).Select("ColumnBoardID", "Rank").Updates(&task).Error; err != nil {
+			return err
+		}
+		task.ColumnBoard = models.ColumnBoard{}
+		return tx.First(&task.ColumnBoard, task.ColumnBoardID).Error
+	})
+	if err == errNeighborNotFound {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	} else if err == errStaleNeighbors || err == errTaskMoved {
+		return c.Status(409).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+			"data":    nil,
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(err.Error())
 	}
 
-	//update database
-	database.DB.Save(&taskInput)
-
-	responseTask := createResponseTask(taskInput)
+	responseTask := createResponseTask(task)
 	return c.Status(200).JSON(responseTask)
 }
 
@@ -252,6 +382,11 @@ func DeleteTask(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeColumn(c, task.ColumnBoardID, permissions.DeleteTask); err != nil {
+		return authorizeError(c, err, permissions.DeleteTask)
+	}
+
 	//soft delete
 	if err := database.DB.Delete(&task).Error; err != nil {
 		return c.Status(404).JSON(err.Error())
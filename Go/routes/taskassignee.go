@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"gofiber/database"
+	"gofiber/middleware"
 	"gofiber/models"
+	"gofiber/permissions"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -41,8 +43,12 @@ func CreateTaskAssignee(c *fiber.Ctx) error {
 		})
 	}
 
+	//assigner is the authenticated user
+	assignedByUser := middleware.CurrentUser(c)
+	taskAssigneeInput.AssignedByUserID = assignedByUser.ID
+
 	//input validation
-	if taskAssigneeInput.TaskID == 0 && taskAssigneeInput.UserID == 0 && taskAssigneeInput.AssignedByUserID == 0 {
+	if taskAssigneeInput.TaskID == 0 && taskAssigneeInput.UserID == 0 {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
 			"message": "All field is required",
@@ -60,12 +66,11 @@ func CreateTaskAssignee(c *fiber.Ctx) error {
 			"message": "Invalid user id",
 			"data":    nil,
 		})
-	} else if taskAssigneeInput.AssignedByUserID == 0 {
-		return c.Status(400).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid assgined by user id",
-			"data":    nil,
-		})
+	}
+
+	//check board permission
+	if err := authorizeTask(c, taskAssigneeInput.TaskID, permissions.ManageAssignee); err != nil {
+		return authorizeError(c, err, permissions.ManageAssignee)
 	}
 
 	// check assigneeUser is exsist
@@ -77,15 +82,19 @@ func CreateTaskAssignee(c *fiber.Ctx) error {
 		})
 	}
 
-	// check assignedByUser is exsist
-	var assignedByUser models.User
-	if err := database.DB.First(&assignedByUser, taskAssigneeInput.AssignedByUserID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
+	//deactivated accounts can not take new assignments
+	if assigneeUser.DeactivatedAt != nil {
+		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "Assigned-by user not found",
+			"message": "Assignee user is deactivated",
 		})
 	}
 
+	//check assignee has verified their email
+	if assigneeUser.EmailVerifiedAt == nil {
+		return unverifiedEmailError(c, assigneeUser)
+	}
+
 	//check title and ensure only one exists
 	var count int64
 	database.DB.Model(&models.TaskAssignee{}).
@@ -115,7 +124,12 @@ func CreateTaskAssignee(c *fiber.Ctx) error {
 func GetTaskAssignees(c *fiber.Ctx) error {
 	taskAssignees := []models.TaskAssignee{}
 
-	database.DB.Find(&taskAssignees)
+	database.DB.
+		Where("task_id IN (?)", database.DB.Model(&models.Task{}).
+			Select("tasks.id").
+			Joins("JOIN column_boards ON column_boards.id = tasks.column_board_id").
+			Where("column_boards.board_id IN (?)", accessibleBoardIDs(c))).
+		Find(&taskAssignees)
 	responseTaskAssignees := []TaskAssignee{}
 
 	for _, taskAssignee := range taskAssignees {
@@ -145,6 +159,12 @@ func GetTaskAssigneeByID(c *fiber.Ctx) error {
 	if err := findTaskAssignee(id, &taskAssignee); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+
+	//check board permission
+	if err := authorizeTask(c, taskAssignee.TaskID, permissions.ViewAssignee); err != nil {
+		return authorizeError(c, err, permissions.ViewAssignee)
+	}
+
 	responseTaskAssignee := createResponseTaskAssignee(taskAssignee)
 	return c.Status(200).JSON(responseTaskAssignee)
 }
@@ -165,6 +185,11 @@ func DeleteTaskAssignee(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//check board permission
+	if err := authorizeTask(c, taskAssignee.TaskID, permissions.ManageAssignee); err != nil {
+		return authorizeError(c, err, permissions.ManageAssignee)
+	}
+
 	//soft delete
 	if err := database.DB.Delete(&taskAssignee).Error; err != nil {
 		return c.Status(404).JSON(err.Error())
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"errors"
+	"gofiber/models"
+	"gofiber/rank"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errNeighborNotFound = errors.New("The neighbor task is not in the target column")
+	errStaleNeighbors   = errors.New("The neighbor tasks are no longer next to each other, reload the column")
+	errTaskMoved        = errors.New("The task was moved or deleted meanwhile, reload the board")
+)
+
+// locks the columns until the end of tx, so tasks are ranked in them one
+// after another. the rows are locked in id order to avoid deadlocks.
+func lockColumns(tx *gorm.DB, columnIDs ...uint) error {
+	slices.Sort(columnIDs)
+	columns := []models.ColumnBoard{}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", slices.Compact(columnIDs)).
+		Order("id").
+		Find(&columns).Error
+}
+
+// returns the rank of a task placed in the column right after the task
+// afterID and right before the task beforeID, 0 means any. without either
+// the task goes to the end. the other tasks of the column are only given new
+// ranks when there is no room left between the neighbors.
+func placeTask(tx *gorm.DB, taskID uint, columnID uint, afterID uint, beforeID uint) (string, error) {
+	tasks := []models.Task{}
+	tx.Select("id", "rank_key").
+		Where("column_board_id = ? AND id != ?", columnID, taskID).
+		Order("rank_key, id").
+		Find(&tasks)
+	indexOf := func(id uint) int {
+		return slices.IndexFunc(tasks, func(task models.Task) bool {
+			return task.ID == id
+		})
+	}
+
+	index := len(tasks)
+	if afterID != 0 {
+		i := indexOf(afterID)
+		if i < 0 {
+			return "", errNeighborNotFound
+		}
+		index = i + 1
+	}
+	if beforeID != 0 {
+		i := indexOf(beforeID)
+		if i < 0 {
+			return "", errNeighborNotFound
+		}
+		if afterID != 0 && i != index {
+			return "", errStaleNeighbors
+		}
+		index = i
+	}
+
+	prev, next := "", ""
+	if index > 0 {
+		prev = tasks[index-1].Rank
+	}
+	if index < len(tasks) {
+		next = tasks[index].Rank
+	}
+	key, err := rank.Between(prev, next)
+	if err == nil && len(key) <= rank.MaxLength {
+		return key, nil
+	}
+
+	//rebalance, the order stays the same and the slot at index is kept free
+	keys := rank.Spread(len(tasks) + 1)
+	for i, task := range tasks {
+		newKey := keys[i]
+		if i >= index {
+			newKey = keys[i+1]
+		}
+		if newKey == task.Rank {
+			continue
+		}
+		if err := tx.Model(&models.Task{}).
+			Where("id = ?", task.ID).
+			UpdateColumn("rank_key", newKey).Error; err != nil {
+			return "", err
+		}
+	}
+	return keys[index], nil
+}
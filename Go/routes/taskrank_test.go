@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"gofiber/database"
+	"gofiber/models"
+	"gofiber/rank"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// creates a column with tasks of the given ranks, in that order
+func createRankedColumn(t *testing.T, ranks ...string) (models.ColumnBoard, []models.Task) {
+	t.Helper()
+
+	board := models.Board{BoardName: "Board"}
+	if err := database.DB.Create(&board).Error; err != nil {
+		t.Fatal(err)
+	}
+	column := models.ColumnBoard{BoardID: board.ID, ColumnName: "To Do"}
+	if err := database.DB.Create(&column).Error; err != nil {
+		t.Fatal(err)
+	}
+	tasks := []models.Task{}
+	for _, key := range ranks {
+		task := models.Task{ColumnBoardID: column.ID, Title: "Task", Rank: key}
+		if err := database.DB.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+	return column, tasks
+}
+
+// ids of the tasks of the column in rank order
+func columnOrderOf(t *testing.T, columnID uint) ([]uint, []string) {
+	t.Helper()
+
+	tasks := []models.Task{}
+	database.DB.Where("column_board_id = ?", columnID).Order("rank_key, id").Find(&tasks)
+	ids, ranks := []uint{}, []string{}
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+		ranks = append(ranks, task.Rank)
+	}
+	return ids, ranks
+}
+
+func TestPlaceTask(t *testing.T) {
+	setupTestDB(t)
+	column, tasks := createRankedColumn(t, "a", "i", "r")
+	a, b, c := tasks[0].ID, tasks[1].ID, tasks[2].ID
+
+	tests := []struct {
+		name     string
+		afterID  uint
+		beforeID uint
+		wantPrev string
+		wantNext string
+		wantErr  error
+	}{
+		{"end", 0, 0, "r", "", nil},
+		{"start", 0, a, "", "a", nil},
+		{"after", b, 0, "i", "r", nil},
+		{"before", 0, b, "a", "i", nil},
+		{"between", a, b, "a", "i", nil},
+		{"not neighbors", a, c, "", "", errStaleNeighbors},
+		{"reversed", b, a, "", "", errStaleNeighbors},
+		{"unknown neighbor", 999, 0, "", "", errNeighborNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := placeTask(database.DB, 0, column.ID, tt.afterID, tt.beforeID)
+			if err != tt.wantErr {
+				t.Fatalf("placeTask() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if key <= tt.wantPrev || (tt.wantNext != "" && key >= tt.wantNext) {
+				t.Errorf("placeTask() = %q, want between %q and %q", key, tt.wantPrev, tt.wantNext)
+			}
+		})
+	}
+
+	//there was room everywhere, the other tasks keep their ranks
+	if _, ranks := columnOrderOf(t, column.ID); !slices.Equal(ranks, []string{"a", "i", "r"}) {
+		t.Errorf("ranks = %v, want them unchanged", ranks)
+	}
+}
+
+func TestPlaceTaskRebalances(t *testing.T) {
+	full := strings.Repeat("z", rank.MaxLength)
+	tests := []struct {
+		name     string
+		ranks    []string
+		afterIdx int
+		wantIdx  int
+	}{
+		//there is no key of MaxLength after the last one
+		{"at the end", []string{"1", "5", full}, 2, 3},
+		//nor between two keys that differ only in their last digit
+		{"in the middle", []string{"1", full[:rank.MaxLength-1] + "y", full, "zzzzzzzzzzzzzzzzzzzzzzzzz1"}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			column, tasks := createRankedColumn(t, tt.ranks...)
+			before, _ := columnOrderOf(t, column.ID)
+
+			moved := models.Task{ColumnBoardID: column.ID, Title: "Moved"}
+			database.DB.Create(&moved)
+			key, err := placeTask(database.DB, moved.ID, column.ID, tasks[tt.afterIdx].ID, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			database.DB.Model(&moved).UpdateColumn("rank_key", key)
+
+			//the order is kept, the moved task lands in its slot and every
+			//key is short again
+			ids, ranks := columnOrderOf(t, column.ID)
+			want := slices.Insert(slices.Clone(before), tt.wantIdx, moved.ID)
+			if !slices.Equal(ids, want) {
+				t.Errorf("order = %v, want %v", ids, want)
+			}
+			if !slices.Equal(ranks, rank.Spread(len(ranks))) {
+				t.Errorf("ranks = %v, want %v", ranks, rank.Spread(len(ranks)))
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package routes
+
+import (
+	"gofiber/auth"
+	"gofiber/config"
+	"gofiber/database"
+	"gofiber/middleware"
+	"gofiber/models"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount = 10
+	// wrong codes allowed on a single login challenge
+	maxChallengeAttempts = 5
+)
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type LoginChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+func hasTwoFactor(userID uint) bool {
+	var count int64
+	database.DB.Model(&models.TwoFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Count(&count)
+	return count > 0
+}
+
+// answers the password step of a login for users with a second factor
+func createLoginChallenge(c *fiber.Ctx, user models.User) error {
+	token, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not create login challenge",
+			"data":    err.Error(),
+		})
+	}
+
+	challenge := models.LoginChallenge{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(config.App.LoginChallengeTTL),
+	}
+
+	//insert database
+	if err := database.DB.Create(&challenge).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(LoginChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         challenge.ExpiresAt,
+	})
+}
+
+// POST second step of a login
+func CompleteLoginChallenge(c *fiber.Ctx) error {
+	type CompleteLoginChallenge struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	var challengeInput CompleteLoginChallenge
+
+	//parsing validation
+	if err := c.BodyParser(&challengeInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//input validation
+	if challengeInput.ChallengeToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Challenge token is required",
+			"data":    nil,
+		})
+	} else if challengeInput.Code == "" && challengeInput.RecoveryCode == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Code or recovery code is required",
+			"data":    nil,
+		})
+	}
+
+	//query to find open LoginChallenge
+	var challenge models.LoginChallenge
+	result := database.DB.
+		Where("token_hash = ? AND expires_at > ? AND used_at IS NULL AND attempts < ?",
+			auth.HashToken(challengeInput.ChallengeToken), time.Now(), maxChallengeAttempts).
+		First(&challenge)
+	if result.RowsAffected == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired login challenge",
+			"data":    nil,
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, challenge.UserID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "User not found",
+			"data":    nil,
+		})
+	}
+
+	//brute-force protection
+	if lockout, locked := activeLoginLockout(user.Username, c.IP()); locked {
+		recordLoginAttempt(c, user.Username, &user.ID, models.LoginBlocked)
+		return tooManyLoginAttempts(c, lockout.LockedUntil)
+	}
+
+	//code validation
+	var valid bool
+	if challengeInput.RecoveryCode != "" {
+		valid = useRecoveryCode(user.ID, challengeInput.RecoveryCode)
+	} else {
+		valid = useTOTPCode(user.ID, challengeInput.Code)
+	}
+	if !valid {
+		database.DB.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1"))
+		registerLoginFailure(c, user.Username, &user.ID)
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Incorrect code",
+		})
+	}
+
+	//the challenge can only be completed once
+	used := database.DB.Model(&models.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if used.RowsAffected == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid or expired login challenge",
+			"data":    nil,
+		})
+	}
+
+	recordLoginAttempt(c, user.Username, &user.ID, models.LoginSucceeded)
+	return createSession(c, user)
+}
+
+// POST start TOTP enrollment
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	if hasTwoFactor(user.ID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor authentication is already enabled",
+			"data":    nil,
+		})
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	//replace any unconfirmed enrollment
+	database.DB.Unscoped().Where("user_id = ?", user.ID).Delete(&models.TwoFactor{})
+	twoFactor := models.TwoFactor{
+		UserID: user.ID,
+		Secret: secret,
+	}
+	if err := database.DB.Create(&twoFactor).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(config.App.TOTPIssuer, user.Username, secret),
+	})
+}
+
+// POST confirm TOTP enrollment with a first code
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	type ConfirmTwoFactor struct {
+		Code string `json:"code"`
+	}
+
+	var confirmInput ConfirmTwoFactor
+
+	//parsing validation
+	if err := c.BodyParser(&confirmInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//query to find pending enrollment
+	var twoFactor models.TwoFactor
+	result := database.DB.
+		Where("user_id = ? AND confirmed_at IS NULL", user.ID).
+		First(&twoFactor)
+	if result.RowsAffected == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "No pending two-factor enrollment",
+			"data":    nil,
+		})
+	}
+
+	step, ok := auth.ValidateTOTP(twoFactor.Secret, confirmInput.Code, time.Now(), twoFactor.LastUsedStep)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Incorrect code",
+		})
+	}
+
+	now := time.Now()
+	twoFactor.ConfirmedAt = &now
+	twoFactor.LastUsedStep = step
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&twoFactor).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	//recovery codes are only shown once
+	return c.Status(200).JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// POST new recovery codes, the old ones stop working
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	type RegenerateRecoveryCodes struct {
+		Code string `json:"code"`
+	}
+
+	var regenerateInput RegenerateRecoveryCodes
+
+	//parsing validation
+	if err := c.BodyParser(&regenerateInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	if !useTOTPCode(user.ID, regenerateInput.Code) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Incorrect code",
+		})
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// DELETE turn off two-factor authentication
+func DisableTwoFactor(c *fiber.Ctx) error {
+	user := middleware.CurrentUser(c)
+
+	type DisableTwoFactor struct {
+		Password string `json:"password"`
+	}
+
+	var disableInput DisableTwoFactor
+
+	//parsing validation
+	if err := c.BodyParser(&disableInput); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+			"data":    err.Error(),
+		})
+	}
+
+	//password confirmation
+	if ok, _ := auth.CheckPassword(user.Password, disableInput.Password); !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": "Incorrect password",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).SendString("Successfully Disabled Two-Factor Authentication")
+}
+
+// checks a TOTP code of a confirmed second factor and burns its time step
+func useTOTPCode(userID uint, code string) bool {
+	var twoFactor models.TwoFactor
+	result := database.DB.
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		First(&twoFactor)
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	step, ok := auth.ValidateTOTP(twoFactor.Secret, code, time.Now(), twoFactor.LastUsedStep)
+	if !ok {
+		return false
+	}
+
+	//only one request can move the step forward
+	used := database.DB.Model(&models.TwoFactor{}).
+		Where("id = ? AND last_used_step < ?", twoFactor.ID, step).
+		Update("last_used_step", step)
+	return used.RowsAffected > 0
+}
+
+func useRecoveryCode(userID uint, code string) bool {
+	codeHash := auth.HashToken(normalizeRecoveryCode(code))
+	used := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return used.RowsAffected > 0
+}
+
+// deletes the recovery codes of the user and returns a new set
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := []string{}
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := auth.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		recoveryCode := models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: auth.HashToken(normalizeRecoveryCode(code)),
+		}
+		if err := tx.Create(&recoveryCode).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// recovery codes are accepted with or without dashes and in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
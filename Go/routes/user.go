@@ -3,31 +3,64 @@ package routes
 import (
 	"errors"
 	"fmt"
+	"gofiber/auth"
 	"gofiber/database"
+	"gofiber/middleware"
 	"gofiber/models"
+	"gofiber/validation"
+	"log"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
+// User is the only shape a user is serialized in. Credentials have no field
+// here, so they can not leak through a response.
 type User struct {
-	IDCard   string `json:"id_card"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	ID            uint   `json:"id"`
+	IDCard        string `json:"id_card"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Deactivated   bool   `json:"deactivated"`
 }
 
-func CreateResponseUser(user models.User) User {
+// CreateResponseUser projects user for viewer. The full IDCard is only shown
+// to the user themselves and to admins, everyone else gets it masked.
+func CreateResponseUser(user models.User, viewer models.User) User {
+	idCard := user.IDCard
+	if viewer.ID != user.ID && !viewer.IsAdmin {
+		idCard = maskIDCard(idCard)
+	}
 	return User{
-		IDCard:   user.IDCard,
-		Username: user.Username,
-		Password: user.Password,
-		Email:    user.Email,
+		ID:            user.ID,
+		IDCard:        idCard,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Deactivated:   user.DeactivatedAt != nil,
 	}
 }
 
+// keeps the last 4 digits only
+func maskIDCard(idCard string) string {
+	if len(idCard) <= 4 {
+		return strings.Repeat("*", len(idCard))
+	}
+	return strings.Repeat("*", len(idCard)-4) + idCard[len(idCard)-4:]
+}
+
 // Post
 func CreateUser(c *fiber.Ctx) error {
-	var userInput models.User
+	type CreateUser struct {
+		IDCard   string `json:"id_card"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+
+	var userInput CreateUser
 
 	//parsing validation
 	if err := c.BodyParser(&userInput); err != nil {
@@ -65,39 +98,121 @@ func CreateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	//duplicated validation
-	result := database.DB.
-		Where("id_card = ?", userInput.IDCard).
-		First(&userInput)
+	//ID card validation
+	userInput.IDCard = validation.NormalizeIDCard(userInput.IDCard)
+	if err := validation.ValidateIDCard(userInput.IDCard); err != nil {
+		return validationError(c, err)
+	}
+
+	//new accounts start unverified
+	user := models.User{
+		Username: userInput.Username,
+		Email:    userInput.Email,
+	}
+	user.SetIDCard(userInput.IDCard)
+
+	//duplicated validation, the IDCard column is encrypted so the lookup
+	//goes through the blind index
+	if user.IDCardIndex != nil {
+		var count int64
+		database.DB.Model(&models.User{}).Unscoped().
+			Where("id_card_index = ?", *user.IDCardIndex).
+			Count(&count)
+
+		if count > 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": "Duplicated ID Card",
+			})
+		}
+	}
 
-	if result.RowsAffected > 0 {
+	//password policy
+	if err := checkNewPassword(user, userInput.Password); err != nil {
+		return validationError(c, err)
+	}
+
+	//hash password
+	hashedPassword, err := auth.HashPassword(userInput.Password)
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   true,
-			"message": "Duplicated ID Card",
+			"message": err.Error(),
+			"data":    nil,
 		})
 	}
 
+	user.Password = hashedPassword
+
 	//insert database
-	database.DB.Create(&userInput)
-	responseUser := CreateResponseUser(userInput)
+	if err := database.DB.Create(&user).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not create user",
+			"data":    err.Error(),
+		})
+	}
+	recordPasswordHistory(database.DB, user.ID, user.Password)
+
+	//the account is created even if the email can not be sent, the user
+	//can ask for a new link
+	if err := sendVerificationEmail(&user); err != nil {
+		log.Printf("verification email for user %d: %v", user.ID, err)
+	}
+
+	responseUser := CreateResponseUser(user, user)
 	return c.Status(200).JSON(responseUser)
 }
 
 // GET All User
 func GetUsers(c *fiber.Ctx) error {
 	users := []models.User{}
+	viewer := middleware.CurrentUser(c)
 
 	//read database
 	database.DB.Find(&users)
 	responseUsers := []User{}
 
 	for _, user := range users {
-		responseUser := CreateResponseUser(user)
+		responseUser := CreateResponseUser(user, viewer)
 		responseUsers = append(responseUsers, responseUser)
 	}
 	return c.Status(200).JSON(responseUsers)
 }
 
+type InvalidIDCard struct {
+	User   User                   `json:"user"`
+	Reason *validation.FieldError `json:"reason"`
+}
+
+// GET users whose stored IDCard fails validation, admins only
+func GetInvalidIDCards(c *fiber.Ctx) error {
+	viewer := middleware.CurrentUser(c)
+	invalidIDCards := []InvalidIDCard{}
+
+	//the column is encrypted, so every row is checked after decryption
+	users := []models.User{}
+	result := database.DB.
+		Where("id_card IS NOT NULL").
+		FindInBatches(&users, 100, func(tx *gorm.DB, batch int) error {
+			for _, user := range users {
+				var fieldErr *validation.FieldError
+				if errors.As(validation.ValidateIDCard(user.IDCard), &fieldErr) {
+					invalidIDCards = append(invalidIDCards, InvalidIDCard{
+						User:   CreateResponseUser(user, viewer),
+						Reason: fieldErr,
+					})
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return c.Status(500).JSON(result.Error.Error())
+	}
+
+	return c.Status(200).JSON(invalidIDCards)
+}
+
 // query to find User in DB
 func findUser(id int, user *models.User) error {
 	database.DB.First(&user, "id=?", id)
@@ -120,7 +235,7 @@ func GetUserByID(c *fiber.Ctx) error {
 	if err := findUser(id, &user); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
-	responseUser := CreateResponseUser(user)
+	responseUser := CreateResponseUser(user, middleware.CurrentUser(c))
 	return c.Status(200).JSON(responseUser)
 }
 
@@ -139,10 +254,20 @@ func UpdateUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	//users can only change their own account
+	if current := middleware.CurrentUser(c); current.ID != user.ID && !current.IsAdmin {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": errForbidden.Error(),
+			"data":    nil,
+		})
+	}
+
 	type UpdateUser struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Username        string `json:"username"`
+		Password        string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+		Email           string `json:"email"`
 	}
 
 	var updateData UpdateUser
@@ -152,25 +277,113 @@ func UpdateUser(c *fiber.Ctx) error {
 		return c.Status(500).JSON(err.Error())
 	}
 
+	//users changing their own password must know the current one, a stolen
+	//session is not enough to take the account over. wrong guesses count as
+	//failed logins.
+	if updateData.Password != "" && middleware.CurrentUser(c).ID == user.ID {
+		if lockout, locked := activeLoginLockout(user.Username, c.IP()); locked {
+			return tooManyLoginAttempts(c, lockout.LockedUntil)
+		}
+		if ok, _ := auth.CheckPassword(user.Password, updateData.CurrentPassword); !ok {
+			registerLoginFailure(c, user.Username, &user.ID)
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": "Current password is incorrect",
+				"data":    nil,
+			})
+		}
+	}
+
+	//duplicated validation
+	if updateData.Username != "" && updateData.Username != user.Username {
+		var count int64
+		database.DB.Model(&models.User{}).Unscoped().
+			Where("username = ? AND id != ?", updateData.Username, user.ID).
+			Count(&count)
+
+		if count > 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": "Duplicated username",
+			})
+		}
+	}
+
 	//null validation - if null, data is still the same
 	if updateData.Username != "" {
 		user.Username = updateData.Username
 	}
+	emailChanged := updateData.Email != "" && updateData.Email != user.Email
+	if emailChanged {
+		user.Email = updateData.Email
+		user.EmailVerifiedAt = nil
+		user.VerificationSentAt = nil
+	}
 	if updateData.Password != "" {
-		user.Password = updateData.Password
+		//password policy, checked against the new username and email
+		if err := checkNewPassword(user, updateData.Password); err != nil {
+			return validationError(c, err)
+		}
+		hashedPassword, err := auth.HashPassword(updateData.Password)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+		user.Password = hashedPassword
 	}
-	if updateData.Email != "" {
-		user.Email = updateData.Email
+
+	//update database, id_card is not editable and may be NULL for
+	//accounts provisioned by single sign-on. the password history and the
+	//revocations are only written with the new password.
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("id_card", "id_card_index").Save(&user).Error; err != nil {
+			return err
+		}
+
+		//a new password logs out every other session and revokes the personal
+		//access tokens, like a password reset
+		if updateData.Password != "" {
+			if err := recordPasswordHistory(tx, user.ID, user.Password); err != nil {
+				return err
+			}
+			if err := revokeUserAccess(tx, user.ID, middleware.CurrentLogin(c).ID); err != nil {
+				return err
+			}
+		}
+
+		//changes made by an admin to another account are audited
+		if current := middleware.CurrentUser(c); current.ID != user.ID {
+			return recordAudit(c, tx, models.AuditUpdateUser, models.AuditTargetUser, user.ID, fiber.Map{
+				"username": updateData.Username != "",
+				"password": updateData.Password != "",
+				"email":    emailChanged,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not update user",
+			"data":    err.Error(),
+		})
 	}
 
-	//update database
-	database.DB.Save(&user)
+	//a new email has to be verified again
+	if emailChanged {
+		if err := sendVerificationEmail(&user); err != nil {
+			log.Printf("verification email for user %d: %v", user.ID, err)
+		}
+	}
 
-	responseUser := CreateResponseUser(user)
+	responseUser := CreateResponseUser(user, middleware.CurrentUser(c))
 	return c.Status(200).JSON(responseUser)
 }
 
-// DELETE
+// DELETE deactivate the account, see deactivateUser
 func DeleteUser(c *fiber.Ctx) error {
 	id, err := c.ParamsInt("id")
 	fmt.Println(id)
@@ -186,10 +399,16 @@ func DeleteUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
-	//soft delete
-	if err := database.DB.Delete(&user).Error; err != nil {
-		return c.Status(404).JSON(err.Error())
+	//users can only change their own account
+	if current := middleware.CurrentUser(c); current.ID != user.ID && !current.IsAdmin {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   true,
+			"message": errForbidden.Error(),
+			"data":    nil,
+		})
 	}
 
-	return c.Status(200).SendString("Successfully Deleted User")
+	//accounts are deactivated rather than deleted, so the boards, tasks and
+	//assignments pointing at them stay readable
+	return deactivateUser(c, user)
 }
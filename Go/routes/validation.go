@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"errors"
+	"gofiber/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// answers a validation error, naming the field and rule of every failure
+// when they are known
+func validationError(c *fiber.Ctx, err error) error {
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": errs.Error(),
+			"data":    errs,
+		})
+	}
+
+	var fieldErr *validation.FieldError
+	if errors.As(err, &fieldErr) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   true,
+			"message": fieldErr.Message,
+			"data":    fieldErr,
+		})
+	}
+	return c.Status(400).JSON(fiber.Map{
+		"error":   true,
+		"message": err.Error(),
+		"data":    nil,
+	})
+}
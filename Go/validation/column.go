@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+const maxColumnNameLength = 50
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidateColumnName checks the name of a board column, any text of up to
+// 50 characters.
+func ValidateColumnName(name string) error {
+	if name == "" {
+		return &FieldError{Field: "column_name", Code: "required", Message: "Column name is required"}
+	}
+	if utf8.RuneCountInString(name) > maxColumnNameLength {
+		return &FieldError{Field: "column_name", Code: "max_length", Message: "Column name must be at most 50 characters"}
+	}
+	return nil
+}
+
+// ValidateColor checks a color written as #rrggbb.
+func ValidateColor(color string) error {
+	if !colorPattern.MatchString(color) {
+		return &FieldError{Field: "color", Code: "format", Message: "Color must be a hex color like #3b82f6"}
+	}
+	return nil
+}
+
+// ValidatePosition checks the position of a column on its board.
+func ValidatePosition(position int) error {
+	if position < 0 {
+		return &FieldError{Field: "position", Code: "min", Message: "Position must not be negative"}
+	}
+	return nil
+}
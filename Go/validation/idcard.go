@@ -0,0 +1,37 @@
+package validation
+
+import "strings"
+
+const idCardLength = 13
+
+// NormalizeIDCard removes the dashes and spaces the number is often
+// written with, "1-2345-67890-12-1" becomes "1234567890121".
+func NormalizeIDCard(idCard string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(idCard))
+}
+
+// ValidateIDCard checks a normalized Thai citizen ID: 13 digits where the
+// last one is the mod-11 checksum of the first 12.
+func ValidateIDCard(idCard string) error {
+	if idCard == "" {
+		return &FieldError{Field: "id_card", Code: "required", Message: "ID Card is required"}
+	}
+	if len(idCard) != idCardLength {
+		return &FieldError{Field: "id_card", Code: "length", Message: "ID Card must be 13 digits"}
+	}
+	for _, r := range idCard {
+		if r < '0' || r > '9' {
+			return &FieldError{Field: "id_card", Code: "format", Message: "ID Card must only contain digits"}
+		}
+	}
+
+	//weights are 13 down to 2
+	sum := 0
+	for i := 0; i < idCardLength-1; i++ {
+		sum += int(idCard[i]-'0') * (idCardLength - i)
+	}
+	if check := (11 - sum%11) % 10; check != int(idCard[idCardLength-1]-'0') {
+		return &FieldError{Field: "id_card", Code: "checksum", Message: "ID Card checksum digit is invalid"}
+	}
+	return nil
+}
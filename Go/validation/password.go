@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// bcrypt only looks at the first 72 bytes of a password
+const maxPasswordBytes = 72
+
+// PasswordPolicy is the set of rules new passwords are checked against.
+// History is not checked here since it needs the stored passwords, it is
+// the number of previous passwords a user can not reuse.
+type PasswordPolicy struct {
+	MinLength     int
+	MinClasses    int
+	CheckUsername bool
+	History       int
+
+	//SHA-1 hashes, upper case hex, of known breached passwords
+	breached map[string]struct{}
+}
+
+// DefaultPasswordPolicy is the policy used for every password change.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+// LoadBreachedPasswords reads a breached password list, one password per
+// line. Lines may also be SHA-1 hashes, optionally followed by ":count" as
+// in the Have I Been Pwned downloads.
+func (p *PasswordPolicy) LoadBreachedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	breached := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1(hash) {
+			breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("breached passwords %s: %w", path, err)
+	}
+	p.breached = breached
+	return nil
+}
+
+// Validate checks password for the account with username and email and
+// returns every rule it fails as Errors.
+func (p PasswordPolicy) Validate(password string, username string, email string) error {
+	errs := Errors{}
+	fail := func(code string, message string) {
+		errs = append(errs, &FieldError{Field: "password", Code: code, Message: message})
+	}
+
+	if password == "" {
+		fail("required", "Password is required")
+		return errs
+	}
+	if length := len([]rune(password)); length < p.MinLength {
+		fail("min_length", fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		fail("max_length", fmt.Sprintf("Password must not be longer than %d bytes", maxPasswordBytes))
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		fail("character_classes", fmt.Sprintf("Password must use at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses))
+	}
+	if p.CheckUsername && similarToAccount(password, username, email) {
+		fail("similar_to_username", "Password must not contain or be contained in the username or email")
+	}
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		fail("breached", "Password appears in a list of breached passwords")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// names shorter than 3 characters are too common to be meaningful
+func similarToAccount(password string, username string, email string) bool {
+	password = strings.ToLower(password)
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, name := range []string{username, localPart} {
+		name = strings.ToLower(name)
+		if len(name) < 3 {
+			continue
+		}
+		if strings.Contains(password, name) || strings.Contains(name, password) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSHA1(value string) bool {
+	if len(value) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
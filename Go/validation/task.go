@@ -0,0 +1,29 @@
+package validation
+
+import "unicode/utf8"
+
+const (
+	maxTaskTitleLength       = 255
+	maxTaskDescriptionLength = 20000
+)
+
+// ValidateTaskTitle checks the title of a task, any text of up to 255
+// characters.
+func ValidateTaskTitle(title string) error {
+	if title == "" {
+		return &FieldError{Field: "title", Code: "required", Message: "Title is required"}
+	}
+	if utf8.RuneCountInString(title) > maxTaskTitleLength {
+		return &FieldError{Field: "title", Code: "max_length", Message: "Title must be at most 255 characters"}
+	}
+	return nil
+}
+
+// ValidateTaskDescription checks the description of a task, which is
+// optional.
+func ValidateTaskDescription(description string) error {
+	if utf8.RuneCountInString(description) > maxTaskDescriptionLength {
+		return &FieldError{Field: "description", Code: "max_length", Message: "Description must be at most 20000 characters"}
+	}
+	return nil
+}
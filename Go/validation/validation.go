@@ -0,0 +1,27 @@
+// Package validation checks user input and reports which field is wrong.
+package validation
+
+import "strings"
+
+// FieldError is an input error on a single field. Code is stable and meant
+// for clients, Message is meant for people.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Errors reports every failed rule at once.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}